@@ -0,0 +1,66 @@
+// Package testhelpers contains helpers shared by the tests of multiple
+// packages.
+package testhelpers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/spotinst/spotinst-sdk-go/spotinst/client"
+	"github.com/stretchr/testify/mock"
+)
+
+// APIError returns an error as returned by the Spotinst API client for a
+// response with the given status code.
+func APIError(statusCode int) error {
+	return client.Errors{
+		{
+			Response: &http.Response{
+				StatusCode: statusCode,
+				Request:    &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/ocean/aws/k8s/cluster"}},
+			},
+			Code:    http.StatusText(statusCode),
+			Message: http.StatusText(statusCode),
+		},
+	}
+}
+
+// OceanClusters returns an ocean cluster for each of clusterIDs. The
+// controller cluster ID equals the ID, the name is the ID prefixed with
+// "ocean-".
+func OceanClusters(clusterIDs ...string) []*aws.Cluster {
+	clusters := make([]*aws.Cluster, 0, len(clusterIDs))
+
+	for _, id := range clusterIDs {
+		clusters = append(clusters, &aws.Cluster{
+			ID:                  spotinst.String(id),
+			ControllerClusterID: spotinst.String(id),
+			Name:                spotinst.String("ocean-" + id),
+		})
+	}
+
+	return clusters
+}
+
+// MockOceanAWSClustersClient is a mock for listing ocean clusters.
+type MockOceanAWSClustersClient struct {
+	mock.Mock
+}
+
+// ListClusters implements clusters.OceanAWSClustersClient.
+func (m *MockOceanAWSClustersClient) ListClusters(
+	ctx context.Context,
+	input *aws.ListClustersInput,
+) (*aws.ListClustersOutput, error) {
+	args := m.Called(ctx, input)
+	output := args.Get(0)
+
+	if output == nil {
+		return nil, args.Error(1)
+	}
+
+	return output.(*aws.ListClustersOutput), args.Error(1)
+}
@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Embed the time zone database, the container image does not ship one.
 
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
 	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/collectors"
 	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/selfmetrics"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,7 +22,6 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spotinst/spotinst-sdk-go/service/mcs"
 	"github.com/spotinst/spotinst-sdk-go/service/ocean"
-	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
 	"github.com/spotinst/spotinst-sdk-go/spotinst/session"
 	"go.uber.org/zap"
 )
@@ -38,16 +41,210 @@ func init() {
 
 func main() {
 	addr := pflag.String("listen-address", ":8080", "The address to listen on for HTTP requests.")
+	clusterRefreshInterval := pflag.Duration(
+		"cluster-refresh-interval",
+		10*time.Minute,
+		"The interval at which the list of ocean clusters is refreshed. Set to 0 to only list clusters at startup.",
+	)
+	clusterRefreshTimeout := pflag.Duration(
+		"cluster-refresh-timeout",
+		time.Minute,
+		"The maximum time spent listing the ocean clusters per refresh, including retries. Set to 0 to disable.",
+	)
 
 	var labelMappings labels.Mappings
 	pflag.Var(
 		&labelMappings,
 		"resource-labels",
-		"Comma-separated list of Kubernetes resource labels (with optional Prometheus label mapping) to propagate onto metrics. E.g. 'mylabel,otherresourcelabel=someprometheuslabel'",
+		"Comma-separated list of Kubernetes resource labels (with optional Prometheus label mapping) to propagate onto metrics. E.g. 'mylabel,otherresourcelabel=someprometheuslabel'. Entries in the format 'prefix*[=template][name,...]' or '~regex[=template][name,...]' propagate all matching labels whose derived Prometheus label name is listed in brackets. E.g. 'team.example.com/*[owner,cost_center]'. Each entry may be followed by ';'-separated value transforms: 'default=value', 'lower', 'trim', 'replace=regex[=>replacement]' and 'maxlen=length'. E.g. 'team;lower;default=unknown'",
+	)
+	var infoLabelMappings labels.Mappings
+	pflag.Var(
+		&infoLabelMappings,
+		"resource-info-labels",
+		"Comma-separated list of Kubernetes resource labels (with optional Prometheus label mapping) to propagate onto the spotinst_ocean_aws_namespace_labels and spotinst_ocean_aws_workload_labels info metrics instead of the cost metrics. Supports the same syntax as --resource-labels.",
+	)
+	labelPrecedence := collectors.LabelPrecedence{collectors.LabelSourceWorkload}
+	pflag.Var(
+		&labelPrecedence,
+		"workload-label-precedence",
+		"Comma-separated list of the sources in which the --resource-labels of workloads are looked up, in order of precedence. One of 'workload', 'namespace', 'workload,namespace' and 'namespace,workload'. E.g. 'workload,namespace' falls back to the labels of the namespace for labels missing on a workload.",
+	)
+	var clusterTagMappings labels.Mappings
+	pflag.Var(
+		&clusterTagMappings,
+		"cluster-tag-labels",
+		"Comma-separated list of ocean cluster tags (with optional Prometheus label mapping) to propagate onto all metrics of the cluster. E.g. 'environment,cost-center=cost_center'",
+	)
+	var clusterStaticLabels clusters.StaticLabels
+	pflag.Var(
+		&clusterStaticLabels,
+		"cluster-labels",
+		"Constant labels to add onto all metrics of the ocean clusters matching the ID or name regular expression (prefixed with '~'). E.g. 'o-12345678:env=prod,region_group=eu' or '~^prod-:env=prod'. Can be specified multiple times.",
+	)
+	costRefreshInterval := pflag.Duration(
+		"cost-refresh-interval",
+		0,
+		"The interval at which cluster costs are fetched in the background. Set to 0 to fetch them on every scrape.",
+	)
+	suggestionsRefreshInterval := pflag.Duration(
+		"suggestions-refresh-interval",
+		0,
+		"The interval at which resource suggestions are fetched in the background. Set to 0 to fetch them on every scrape.",
+	)
+	projectedSavings := pflag.Bool(
+		"projected-savings",
+		false,
+		"Export the projected monthly savings of applying resource suggestions. Requires the month-to-date costs of all clusters, which are fetched in addition to the configured cost windows.",
+	)
+	savingsCPUWeight := pflag.Float64(
+		"savings-cpu-weight",
+		collectors.DefaultSavingsCPUWeight,
+		"The share of a workload's cost attributed to its CPU requests when projecting savings from resource suggestions, between 0 and 1. The remaining share is attributed to its memory requests.",
+	)
+	concurrency := pflag.Int(
+		"concurrency",
+		4,
+		"The maximum number of ocean clusters for which data is fetched from the Spotinst API in parallel.",
+	)
+	scrapeTimeout := pflag.Duration(
+		"scrape-timeout",
+		0,
+		"The maximum time spent fetching data from the Spotinst API per scrape, including retries. Scrapes always give up shortly before the scrape timeout sent by Prometheus. Set to 0 to only rely on the latter.",
+	)
+	refreshTimeout := pflag.Duration(
+		"refresh-timeout",
+		5*time.Minute,
+		"The maximum time spent fetching data from the Spotinst API per background refresh of costs or resource suggestions, including retries. Set to 0 to disable.",
+	)
+	apiMaxAttempts := pflag.Int("api-max-attempts", 3, "The maximum number of attempts for Spotinst API calls failing with retryable errors.")
+	apiRateLimit := pflag.Float64("api-rate-limit", 0, "The maximum number of Spotinst API calls per second. Set to 0 to disable.")
+	timezone := pflag.String(
+		"timezone",
+		"Local",
+		"The IANA time zone used for calculating day and month boundaries of cost queries, e.g. 'Europe/Berlin'.",
+	)
+
+	var costWindows collectors.CostWindows
+	pflag.Var(
+		&costWindows,
+		"cost-windows",
+		"Comma-separated list of time windows to collect costs for. One of 'month-to-date', 'today', 'yesterday', 'last-7-days' and 'previous-month'. Defaults to 'month-to-date'.",
+	)
+	previousMonthCosts := pflag.Bool(
+		"previous-month-costs",
+		false,
+		"Collect the final costs of the previous month in addition to the configured cost windows. Shorthand for adding 'previous-month' to --cost-windows.",
+	)
+	forecastMethod := pflag.String(
+		"cost-forecast",
+		string(collectors.ForecastNone),
+		"The method used to forecast end-of-month costs. One of 'none', 'linear' and 'run-rate'.",
+	)
+	forecastRunRateDays := pflag.Int(
+		"cost-forecast-run-rate-days",
+		7,
+		"The number of full days before today the daily run-rate of the 'run-rate' cost forecast is based on.",
+	)
+
+	allocationMode := pflag.String(
+		"cost-allocation",
+		string(collectors.AllocationNone),
+		"The method used to redistribute the costs of shared namespaces and unallocated costs to the remaining namespaces. One of 'none', 'proportional' and 'even'.",
+	)
+	sharedNamespaces := pflag.StringSlice(
+		"shared-namespaces",
+		nil,
+		"Comma-separated list of namespaces whose costs are redistributed to the remaining namespaces if --cost-allocation is enabled. E.g. 'kube-system,monitoring'",
+	)
+	var normalizationRules collectors.NormalizationRules
+	pflag.Var(
+		&normalizationRules,
+		"workload-name-normalization",
+		"Rule in the format '[kind:]regex[=>replacement]' for normalizing workload names before their costs are aggregated, e.g. 'job:-[a-z0-9]{5}$'. Can be specified multiple times, rules are applied in order. Use 'default' to include the default rule removing timestamps and UUIDs, which is used if no rule is configured.",
 	)
+	var workloadsPerNamespace, workloadsPerCluster collectors.WorkloadLimits
+	pflag.Var(
+		&workloadsPerNamespace,
+		"workload-limit-per-namespace",
+		"Comma-separated list of the maximum number of workloads per namespace (with optional workload kind) for which costs are exported individually. E.g. '50,job=10'",
+	)
+	pflag.Var(
+		&workloadsPerCluster,
+		"workload-limit-per-cluster",
+		"Comma-separated list of the maximum number of workloads per cluster (with optional workload kind) for which costs are exported individually. E.g. '500,job=100'",
+	)
+	dailyCostsLookbackDays := pflag.Int(
+		"daily-costs-lookback-days",
+		0,
+		"The number of full days before today to collect daily costs with historical timestamps for. Set to 0 to disable.",
+	)
+
+	var clusterFilter clusters.Filter
+	pflag.StringSliceVar(&clusterFilter.IncludeIDs, "include-clusters", nil, "Comma-separated list of ocean cluster IDs to collect metrics for. Defaults to all clusters.")
+	pflag.StringSliceVar(&clusterFilter.ExcludeIDs, "exclude-clusters", nil, "Comma-separated list of ocean cluster IDs to exclude.")
+	pflag.Var(&clusterFilter.IncludeNames, "include-cluster-names", "Regular expression matching the names of ocean clusters to collect metrics for. Can be specified multiple times.")
+	pflag.Var(&clusterFilter.ExcludeNames, "exclude-cluster-names", "Regular expression matching the names of ocean clusters to exclude. Can be specified multiple times.")
+	pflag.Var(&clusterFilter.IncludeTags, "include-cluster-tags", "Comma-separated list of ocean cluster tags (with optional value) to collect metrics for. E.g. 'env=prod,team'")
+	pflag.Var(&clusterFilter.ExcludeTags, "exclude-cluster-tags", "Comma-separated list of ocean cluster tags (with optional value) to exclude. E.g. 'env=sandbox,ephemeral'")
 	pflag.Parse()
 
 	logger.Info("propagating resource labels", "mapping", labelMappings)
+	logger.Info("propagating resource info labels", "mapping", infoLabelMappings)
+	logger.Info("propagating cluster tags", "mapping", clusterTagMappings)
+
+	// The info metrics don't share any metric with the cost labels, so both
+	// are only checked against the cluster labels.
+	err := labels.ValidateUnique(
+		labelMappings.LabelNames(),
+		clusterTagMappings.LabelNames(),
+		clusterStaticLabels.LabelNames(),
+	)
+	if err == nil {
+		err = labels.ValidateUnique(
+			infoLabelMappings.LabelNames(),
+			clusterTagMappings.LabelNames(),
+			clusterStaticLabels.LabelNames(),
+		)
+	}
+	if err != nil {
+		logger.Error(err, "conflicting label configuration")
+		os.Exit(1)
+	}
+
+	if *previousMonthCosts {
+		if len(costWindows) == 0 {
+			costWindows = append(costWindows, collectors.CostWindowMonthToDate)
+		}
+
+		if err := costWindows.Set(string(collectors.CostWindowPreviousMonth)); err != nil {
+			logger.Error(err, "invalid cost windows")
+			os.Exit(1)
+		}
+	}
+
+	forecast, err := collectors.ParseForecastMethod(*forecastMethod)
+	if err != nil {
+		logger.Error(err, "invalid cost forecast method")
+		os.Exit(1)
+	}
+
+	allocation, err := collectors.ParseAllocationMode(*allocationMode)
+	if err != nil {
+		logger.Error(err, "invalid cost allocation mode")
+		os.Exit(1)
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Error(err, "invalid timezone")
+		os.Exit(1)
+	}
+
+	if *savingsCPUWeight < 0 || *savingsCPUWeight > 1 {
+		logger.Error(fmt.Errorf("must be between 0 and 1, got %v", *savingsCPUWeight), "invalid savings CPU weight")
+		os.Exit(1)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go handleSignals(cancel)
@@ -57,23 +254,166 @@ func main() {
 
 	oceanAWSClient := ocean.New(sess).CloudProviderAWS()
 
-	clusters, err := getOceanAWSClusters(ctx, oceanAWSClient)
-	if err != nil {
+	exporterMetrics := selfmetrics.New()
+
+	retryPolicy := collectors.RetryPolicy{
+		MaxAttempts:    *apiMaxAttempts,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+	rateLimiter := collectors.NewRateLimiter(*apiRateLimit)
+
+	inventory := clusters.NewInventory(
+		logger,
+		collectors.NewRetryingOceanAWSClustersClient(oceanAWSClient, retryPolicy, rateLimiter, exporterMetrics),
+		clusterFilter,
+		*clusterRefreshTimeout,
+	)
+	if err := inventory.Refresh(ctx); err != nil {
 		logger.Error(err, "failed to fetch ocean clusters")
 		os.Exit(1)
 	}
 
+	if *clusterRefreshInterval > 0 {
+		go inventory.Run(ctx, *clusterRefreshInterval)
+	}
+
+	costsClient := collectors.NewRetryingOceanAWSClusterCostsClient(mcsClient, retryPolicy, rateLimiter, exporterMetrics)
+	costCache := collectors.NewCostCache()
+
+	// Resource suggestions carry neither labels nor costs, so both are taken
+	// from the cost data if needed.
+	var workloadIndex *collectors.WorkloadIndex
+	if len(labelMappings) > 0 || *projectedSavings {
+		workloadIndex = collectors.NewWorkloadIndex()
+	}
+
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(collectors.NewOceanAWSClusterCostsCollector(ctx, logger, mcsClient, clusters, labelMappings))
-	registry.MustRegister(collectors.NewOceanAWSResourceSuggestionsCollector(ctx, logger, oceanAWSClient, clusters))
+	registry.MustRegister(exporterMetrics)
+	registry.MustRegister(inventory)
+
+	// Collectors fetching data from the Spotinst API are bound to the context
+	// of each scrape request, see metricsHandler.
+	var apiCollectors []collectors.ContextCollector
+
+	apiCollectors = append(apiCollectors, collectors.NewOceanAWSClusterCostsCollector(
+		ctx, logger, costsClient, inventory, labelMappings,
+		collectors.WithCostWindows(costWindows...),
+		collectors.WithCostCache(costCache),
+		collectors.WithLocation(location),
+		collectors.WithCostForecast(forecast, *forecastRunRateDays),
+		collectors.WithCostAllocation(allocation, *sharedNamespaces...),
+		collectors.WithInfoLabelMappings(infoLabelMappings),
+		collectors.WithWorkloadIndex(workloadIndex),
+		collectors.WithNormalizationRules(normalizationRules),
+		collectors.WithWorkloadLimits(workloadsPerNamespace, workloadsPerCluster),
+		collectors.WithLabelPrecedence(labelPrecedence),
+		collectors.WithRefreshInterval(*costRefreshInterval),
+		collectors.WithConcurrency(*concurrency),
+		collectors.WithScrapeTimeout(*scrapeTimeout),
+		collectors.WithRefreshTimeout(*refreshTimeout),
+		collectors.WithMetrics(exporterMetrics),
+		collectors.WithClusterTagMappings(clusterTagMappings),
+		collectors.WithClusterStaticLabels(clusterStaticLabels),
+	))
+
+	if *dailyCostsLookbackDays > 0 {
+		apiCollectors = append(apiCollectors, collectors.NewOceanAWSClusterDailyCostsCollector(
+			ctx, logger, costsClient, inventory, labelMappings, *dailyCostsLookbackDays,
+			collectors.WithCostCache(costCache),
+			collectors.WithLocation(location),
+			collectors.WithNormalizationRules(normalizationRules),
+			collectors.WithWorkloadLimits(workloadsPerNamespace, workloadsPerCluster),
+			collectors.WithLabelPrecedence(labelPrecedence),
+			collectors.WithRefreshInterval(*costRefreshInterval),
+			collectors.WithConcurrency(*concurrency),
+			collectors.WithScrapeTimeout(*scrapeTimeout),
+			collectors.WithRefreshTimeout(*refreshTimeout),
+			collectors.WithMetrics(exporterMetrics),
+			collectors.WithClusterTagMappings(clusterTagMappings),
+			collectors.WithClusterStaticLabels(clusterStaticLabels),
+		))
+	}
+
+	apiCollectors = append(apiCollectors, collectors.NewOceanAWSResourceSuggestionsCollector(
+		ctx, logger,
+		collectors.NewRetryingOceanAWSResourceSuggestionsClient(oceanAWSClient, retryPolicy, rateLimiter, exporterMetrics),
+		inventory,
+		labelMappings,
+		collectors.WithRefreshInterval(*suggestionsRefreshInterval),
+		collectors.WithWorkloadIndex(workloadIndex),
+		collectors.WithProjectedSavings(*projectedSavings, *savingsCPUWeight),
+		collectors.WithLabelPrecedence(labelPrecedence),
+		collectors.WithConcurrency(*concurrency),
+		collectors.WithScrapeTimeout(*scrapeTimeout),
+		collectors.WithRefreshTimeout(*refreshTimeout),
+		collectors.WithMetrics(exporterMetrics),
+		collectors.WithClusterTagMappings(clusterTagMappings),
+		collectors.WithClusterStaticLabels(clusterStaticLabels),
+	))
 
 	handler := http.NewServeMux()
 	handler.HandleFunc("/healthz", healthzHandler)
-	handler.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	handler.Handle("/metrics", metricsHandler(registry, apiCollectors...))
 
 	listenAndServe(ctx, handler, *addr)
 }
 
+// scrapeTimeoutMargin is subtracted from the scrape timeout sent by Prometheus
+// to leave time for encoding and transferring the metrics.
+const scrapeTimeoutMargin = 500 * time.Millisecond
+
+// metricsHandler serves the metrics of apiCollectors and registry, in that
+// order. The former collect metrics using the context of the scrape request,
+// which expires shortly before the timeout announced by Prometheus in the
+// X-Prometheus-Scrape-Timeout-Seconds header.
+func metricsHandler(registry *prometheus.Registry, apiCollectors ...collectors.ContextCollector) http.Handler {
+	// Register the collectors once up front to fail at startup instead of on
+	// the first scrape if they are inconsistent.
+	prometheus.NewRegistry().MustRegister(toCollectors(apiCollectors)...)
+
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: true}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		scrapeRegistry := prometheus.NewRegistry()
+		for _, collector := range apiCollectors {
+			scrapeRegistry.MustRegister(collectors.BindContext(ctx, collector))
+		}
+
+		// Gatherers are gathered in order, so the API collectors run first and
+		// the metrics about them in registry reflect the current scrape.
+		promhttp.HandlerFor(prometheus.Gatherers{scrapeRegistry, registry}, opts).ServeHTTP(w, r)
+	})
+}
+
+// scrapeContext returns the context of the scrape request r, with a deadline
+// derived from the scrape timeout sent by Prometheus, if any.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*scrapeTimeoutMargin {
+		timeout -= scrapeTimeoutMargin
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+func toCollectors(apiCollectors []collectors.ContextCollector) []prometheus.Collector {
+	result := make([]prometheus.Collector, len(apiCollectors))
+	for i, collector := range apiCollectors {
+		result[i] = collector
+	}
+
+	return result
+}
+
 func handleSignals(cancelFunc func()) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
@@ -110,15 +450,6 @@ func listenAndServe(ctx context.Context, handler http.Handler, addr string) {
 	}
 }
 
-func getOceanAWSClusters(ctx context.Context, client aws.Service) ([]*aws.Cluster, error) {
-	output, err := client.ListClusters(ctx, &aws.ListClustersInput{})
-	if err != nil {
-		return nil, err
-	}
-
-	return output.Clusters, nil
-}
-
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err := w.Write([]byte("ok")); err != nil {
 		logger.Error(err, "failed to write health check status")
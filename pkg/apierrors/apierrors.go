@@ -0,0 +1,100 @@
+// Package apierrors classifies errors returned by the Spotinst API client.
+package apierrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/spotinst/spotinst-sdk-go/spotinst/client"
+)
+
+// Error classes returned by Class.
+const (
+	ClassCanceled    = "canceled"
+	ClassTimeout     = "timeout"
+	ClassRateLimited = "rate_limited"
+	ClassServer      = "server_error"
+	ClassClient      = "client_error"
+	ClassNetwork     = "network"
+	// ClassInvalidResponse is used for responses that are not valid JSON.
+	// The Spotinst API client returns the bare decoding error for error
+	// responses without a JSON body, e.g. the HTML page of a load balancer
+	// answering with 502 or 503, which loses the status code.
+	ClassInvalidResponse = "invalid_response"
+	ClassUnknown         = "unknown"
+)
+
+// Class classifies errors returned by the Spotinst API client.
+func Class(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	}
+
+	if statusCode, ok := StatusCode(err); ok {
+		switch {
+		case statusCode == http.StatusTooManyRequests:
+			return ClassRateLimited
+		case statusCode >= http.StatusInternalServerError:
+			return ClassServer
+		default:
+			return ClassClient
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassNetwork
+	}
+
+	if isDecodeError(err) {
+		return ClassInvalidResponse
+	}
+
+	return ClassUnknown
+}
+
+// IsTransient returns true for errors caused by rate limiting, server errors,
+// network errors and invalid responses, which may succeed if the call is
+// repeated.
+func IsTransient(err error) bool {
+	switch Class(err) {
+	case ClassRateLimited, ClassServer, ClassNetwork, ClassInvalidResponse:
+		return true
+	default:
+		return false
+	}
+}
+
+// StatusCode extracts the HTTP status code from Spotinst API errors.
+func StatusCode(err error) (int, bool) {
+	var apiErrs client.Errors
+	if errors.As(err, &apiErrs) && len(apiErrs) > 0 && apiErrs[0].Response != nil {
+		return apiErrs[0].Response.StatusCode, true
+	}
+
+	var apiErr client.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return apiErr.Response.StatusCode, true
+	}
+
+	return 0, false
+}
+
+// isDecodeError returns true if err was caused by decoding a response body
+// which is empty, truncated or not JSON at all.
+func isDecodeError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	return errors.As(err, &syntaxErr) ||
+		errors.As(err, &typeErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
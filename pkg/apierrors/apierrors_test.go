@@ -0,0 +1,68 @@
+package apierrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/spotinst/spotinst-sdk-go/spotinst/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClass(t *testing.T) {
+	testCases := []struct {
+		err       error
+		expected  string
+		transient bool
+	}{
+		{err: context.Canceled, expected: ClassCanceled},
+		{err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), expected: ClassTimeout},
+		{err: testhelpers.APIError(http.StatusTooManyRequests), expected: ClassRateLimited, transient: true},
+		{err: testhelpers.APIError(http.StatusBadGateway), expected: ClassServer, transient: true},
+		{err: testhelpers.APIError(http.StatusNotFound), expected: ClassClient},
+		{err: &url.Error{Op: "Get", URL: "https://api.spotinst.io", Err: timeoutError{}}, expected: ClassNetwork, transient: true},
+		{err: gatewayError(t), expected: ClassInvalidResponse, transient: true},
+		{err: io.EOF, expected: ClassInvalidResponse, transient: true},
+		{err: errors.New("boom"), expected: ClassUnknown},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, Class(testCase.err), testCase.err.Error())
+		assert.Equal(t, testCase.transient, IsTransient(testCase.err), testCase.err.Error())
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	statusCode, ok := StatusCode(fmt.Errorf("wrapped: %w", testhelpers.APIError(http.StatusNotFound)))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	_, ok = StatusCode(errors.New("boom"))
+	assert.False(t, ok)
+}
+
+// gatewayError returns the error of the Spotinst API client for a 502 response
+// with an HTML body, as returned by load balancers.
+func gatewayError(t *testing.T) error {
+	_, err := client.RequireOK(&http.Response{
+		StatusCode: http.StatusBadGateway,
+		Body:       io.NopCloser(strings.NewReader("<html><body>502 Bad Gateway</body></html>")),
+		Request:    &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/ocean/aws/k8s/cluster"}},
+	}, nil)
+	require.Error(t, err)
+
+	return err
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,221 @@
+package clusters
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+var errEmptyTagKey = errors.New("tag keys must not be empty")
+
+// Filter selects the Ocean clusters that metrics are collected for.
+//
+// A cluster is selected if it matches every non-empty include criterion and
+// none of the exclude criteria. Within a single criterion, matching any of the
+// configured values is sufficient. The zero value selects all clusters.
+type Filter struct {
+	IncludeIDs   []string
+	ExcludeIDs   []string
+	IncludeNames Patterns
+	ExcludeNames Patterns
+	IncludeTags  TagSelectors
+	ExcludeTags  TagSelectors
+}
+
+// Apply returns the clusters selected by the filter.
+func (f Filter) Apply(clusters []*aws.Cluster) []*aws.Cluster {
+	selected := make([]*aws.Cluster, 0, len(clusters))
+
+	for _, cluster := range clusters {
+		if f.Matches(cluster) {
+			selected = append(selected, cluster)
+		}
+	}
+
+	return selected
+}
+
+// Matches returns true if the cluster is selected by the filter.
+func (f Filter) Matches(cluster *aws.Cluster) bool {
+	id := spotinst.StringValue(cluster.ID)
+	name := spotinst.StringValue(cluster.Name)
+	tags := Tags(cluster)
+
+	if len(f.IncludeIDs) > 0 && !containsString(f.IncludeIDs, id) {
+		return false
+	}
+
+	if len(f.IncludeNames) > 0 && !f.IncludeNames.MatchAny(name) {
+		return false
+	}
+
+	if len(f.IncludeTags) > 0 && !f.IncludeTags.MatchAny(tags) {
+		return false
+	}
+
+	return !containsString(f.ExcludeIDs, id) &&
+		!f.ExcludeNames.MatchAny(name) &&
+		!f.ExcludeTags.MatchAny(tags)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tags returns the tags from the cluster's launch specification as a map.
+func Tags(cluster *aws.Cluster) map[string]string {
+	if cluster.Compute == nil || cluster.Compute.LaunchSpecification == nil {
+		return nil
+	}
+
+	tags := make(map[string]string, len(cluster.Compute.LaunchSpecification.Tags))
+
+	for _, tag := range cluster.Compute.LaunchSpecification.Tags {
+		tags[spotinst.StringValue(tag.Key)] = spotinst.StringValue(tag.Value)
+	}
+
+	return tags
+}
+
+// Patterns is a list of regular expressions for matching cluster names.
+type Patterns []*regexp.Regexp
+
+// MatchAny returns true if any of the patterns matches s.
+func (p Patterns) MatchAny(s string) bool {
+	for _, pattern := range p {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set implements pflag.Value.
+//
+// Each call adds a single pattern, since regular expressions may contain
+// commas.
+func (p *Patterns) Set(value string) error {
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+
+	*p = append(*p, pattern)
+	return nil
+}
+
+// String implements pflag.Value.
+func (p Patterns) String() string {
+	patterns := make([]string, 0, len(p))
+
+	for _, pattern := range p {
+		patterns = append(patterns, pattern.String())
+	}
+
+	return strings.Join(patterns, ",")
+}
+
+// Type implements pflag.Value.
+func (p Patterns) Type() string {
+	return "regex"
+}
+
+// TagSelector matches clusters by tag. If value is empty, the presence of the
+// tag key is sufficient.
+type TagSelector struct {
+	key   string
+	value string
+}
+
+// Matches returns true if the tags match the selector.
+func (s TagSelector) Matches(tags map[string]string) bool {
+	value, ok := tags[s.key]
+	if !ok {
+		return false
+	}
+
+	return s.value == "" || s.value == value
+}
+
+// TagSelectors is a list of tag selectors.
+type TagSelectors []TagSelector
+
+// ParseTagSelectors parses tag selectors from an input string.
+//
+// Returns an error if the input is malformed.
+func ParseTagSelectors(input string) (TagSelectors, error) {
+	pairs := strings.Split(input, ",")
+	selectors := make(TagSelectors, 0, len(pairs))
+
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+
+		selector := TagSelector{key: parts[0]}
+		if len(parts) == 2 {
+			selector.value = parts[1]
+		}
+
+		if selector.key == "" {
+			return nil, errEmptyTagKey
+		}
+
+		selectors = append(selectors, selector)
+	}
+
+	return selectors, nil
+}
+
+// MatchAny returns true if any of the selectors matches the tags.
+func (s TagSelectors) MatchAny(tags map[string]string) bool {
+	for _, selector := range s {
+		if selector.Matches(tags) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set implements pflag.Value.
+func (s *TagSelectors) Set(value string) error {
+	selectors, err := ParseTagSelectors(value)
+	if err != nil {
+		return err
+	}
+
+	*s = append(*s, selectors...)
+	return nil
+}
+
+// String implements pflag.Value.
+func (s TagSelectors) String() string {
+	var sb strings.Builder
+
+	for i, selector := range s {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(selector.key)
+		if selector.value != "" {
+			sb.WriteRune('=')
+			sb.WriteString(selector.value)
+		}
+	}
+
+	return sb.String()
+}
+
+// Type implements pflag.Value.
+func (s TagSelectors) Type() string {
+	return "key[=value]"
+}
@@ -0,0 +1,128 @@
+package clusters
+
+import (
+	"testing"
+
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	clusters := []*aws.Cluster{
+		taggedCluster("o-1", "prod-eu", map[string]string{"env": "prod", "team": "platform"}),
+		taggedCluster("o-2", "sandbox-eu", map[string]string{"env": "sandbox"}),
+		taggedCluster("o-3", "ci-1234", map[string]string{"env": "ci", "ephemeral": ""}),
+		taggedCluster("o-4", "prod-us", nil),
+	}
+
+	mustPatterns := func(patterns ...string) Patterns {
+		var p Patterns
+		for _, pattern := range patterns {
+			require.NoError(t, p.Set(pattern))
+		}
+		return p
+	}
+
+	mustTagSelectors := func(input string) TagSelectors {
+		selectors, err := ParseTagSelectors(input)
+		require.NoError(t, err)
+		return selectors
+	}
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{
+			name:     "zero value selects all",
+			expected: []string{"o-1", "o-2", "o-3", "o-4"},
+		},
+		{
+			name:     "include ids",
+			filter:   Filter{IncludeIDs: []string{"o-2", "o-4"}},
+			expected: []string{"o-2", "o-4"},
+		},
+		{
+			name:     "exclude ids",
+			filter:   Filter{ExcludeIDs: []string{"o-2"}},
+			expected: []string{"o-1", "o-3", "o-4"},
+		},
+		{
+			name:     "include names",
+			filter:   Filter{IncludeNames: mustPatterns("^prod-")},
+			expected: []string{"o-1", "o-4"},
+		},
+		{
+			name:     "exclude names",
+			filter:   Filter{ExcludeNames: mustPatterns("^sandbox-", `^ci-\d+$`)},
+			expected: []string{"o-1", "o-4"},
+		},
+		{
+			name:     "include tags",
+			filter:   Filter{IncludeTags: mustTagSelectors("env=prod,env=sandbox")},
+			expected: []string{"o-1", "o-2"},
+		},
+		{
+			name:     "exclude tag key",
+			filter:   Filter{ExcludeTags: mustTagSelectors("ephemeral")},
+			expected: []string{"o-1", "o-2", "o-4"},
+		},
+		{
+			name: "include criteria must all match, exclude wins",
+			filter: Filter{
+				IncludeNames: mustPatterns("-eu$"),
+				IncludeTags:  mustTagSelectors("env"),
+				ExcludeIDs:   []string{"o-2"},
+			},
+			expected: []string{"o-1"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var ids []string
+
+			for _, cluster := range testCase.filter.Apply(clusters) {
+				ids = append(ids, spotinst.StringValue(cluster.ID))
+			}
+
+			assert.Equal(t, testCase.expected, ids)
+		})
+	}
+}
+
+func TestTagSelectors(t *testing.T) {
+	t.Run("valid input", func(t *testing.T) {
+		selectors, err := ParseTagSelectors("env=prod,ephemeral")
+		assert.NoError(t, err)
+		assert.Equal(t, TagSelectors{{key: "env", value: "prod"}, {key: "ephemeral"}}, selectors)
+		assert.Equal(t, "env=prod,ephemeral", selectors.String())
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		for _, input := range []string{"", "=prod", "env=prod,"} {
+			_, err := ParseTagSelectors(input)
+			assert.Error(t, err)
+		}
+	})
+}
+
+func taggedCluster(id, name string, tags map[string]string) *aws.Cluster {
+	launchSpec := &aws.LaunchSpecification{}
+
+	for key, value := range tags {
+		launchSpec.Tags = append(launchSpec.Tags, &aws.Tag{
+			Key:   spotinst.String(key),
+			Value: spotinst.String(value),
+		})
+	}
+
+	return &aws.Cluster{
+		ID:      spotinst.String(id),
+		Name:    spotinst.String(name),
+		Compute: &aws.Compute{LaunchSpecification: launchSpec},
+	}
+}
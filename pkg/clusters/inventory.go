@@ -0,0 +1,176 @@
+// Package clusters keeps track of the Spotinst Ocean clusters that metrics
+// are collected for.
+package clusters
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// OceanAWSClustersClient is the interface for something that can list Ocean
+// clusters.
+//
+// It is implemented by the Spotinst *aws.ServiceOp client.
+type OceanAWSClustersClient interface {
+	ListClusters(context.Context, *aws.ListClustersInput) (*aws.ListClustersOutput, error)
+}
+
+// Static is a fixed list of Ocean clusters.
+type Static []*aws.Cluster
+
+// Clusters returns the list of Ocean clusters.
+func (s Static) Clusters() []*aws.Cluster {
+	return s
+}
+
+// Inventory is a thread-safe list of Ocean clusters which can be refreshed
+// periodically. It is a prometheus collector for metrics about the discovered
+// clusters.
+type Inventory struct {
+	logger   logr.Logger
+	client   OceanAWSClustersClient
+	filter   Filter
+	timeout  time.Duration
+	mu       sync.RWMutex
+	clusters []*aws.Cluster
+	count    prometheus.Gauge
+	added    prometheus.Counter
+	removed  prometheus.Counter
+}
+
+// NewInventory creates a new, empty *Inventory which only contains the
+// clusters selected by filter. Each refresh is given up after timeout, a zero
+// timeout means no limit. Failed API calls are expected to be retried and
+// recorded by client. Call Refresh or Run to populate it.
+func NewInventory(
+	logger logr.Logger,
+	client OceanAWSClustersClient,
+	filter Filter,
+	timeout time.Duration,
+) *Inventory {
+	return &Inventory{
+		logger:  logger,
+		client:  client,
+		filter:  filter,
+		timeout: timeout,
+		count: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "spotinst",
+			Subsystem: "exporter",
+			Name:      "clusters",
+			Help:      "Number of ocean clusters that metrics are collected for",
+		}),
+		added: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "spotinst",
+			Subsystem: "exporter",
+			Name:      "clusters_added_total",
+			Help:      "Total number of ocean clusters discovered",
+		}),
+		removed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "spotinst",
+			Subsystem: "exporter",
+			Name:      "clusters_removed_total",
+			Help:      "Total number of ocean clusters that disappeared",
+		}),
+	}
+}
+
+// Clusters returns the current list of Ocean clusters.
+func (i *Inventory) Clusters() []*aws.Cluster {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.clusters
+}
+
+// Refresh fetches the list of Ocean clusters and replaces the current one.
+//
+// On error, the current list of clusters is left untouched.
+func (i *Inventory) Refresh(ctx context.Context) error {
+	if i.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.timeout)
+		defer cancel()
+	}
+
+	output, err := i.client.ListClusters(ctx, &aws.ListClustersInput{})
+	if err != nil {
+		return err
+	}
+
+	i.update(i.filter.Apply(output.Clusters))
+
+	return nil
+}
+
+// Run refreshes the inventory every interval until ctx is cancelled. Errors
+// are logged and the previous list of clusters is retained.
+func (i *Inventory) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.Refresh(ctx); err != nil {
+				i.logger.Error(err, "failed to refresh ocean clusters")
+			}
+		}
+	}
+}
+
+func (i *Inventory) update(clusters []*aws.Cluster) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	previous := clusterIDs(i.clusters)
+	current := clusterIDs(clusters)
+
+	for _, cluster := range clusters {
+		if _, ok := previous[spotinst.StringValue(cluster.ID)]; !ok {
+			i.logger.Info("discovered ocean cluster", "ocean_id", spotinst.StringValue(cluster.ID), "ocean_name", spotinst.StringValue(cluster.Name))
+			i.added.Inc()
+		}
+	}
+
+	for _, cluster := range i.clusters {
+		if _, ok := current[spotinst.StringValue(cluster.ID)]; !ok {
+			i.logger.Info("ocean cluster removed", "ocean_id", spotinst.StringValue(cluster.ID), "ocean_name", spotinst.StringValue(cluster.Name))
+			i.removed.Inc()
+		}
+	}
+
+	i.clusters = clusters
+	i.count.Set(float64(len(clusters)))
+}
+
+func clusterIDs(clusters []*aws.Cluster) map[string]struct{} {
+	ids := make(map[string]struct{}, len(clusters))
+
+	for _, cluster := range clusters {
+		ids[spotinst.StringValue(cluster.ID)] = struct{}{}
+	}
+
+	return ids
+}
+
+// Describe implements the prometheus.Collector interface.
+func (i *Inventory) Describe(ch chan<- *prometheus.Desc) {
+	i.count.Describe(ch)
+	i.added.Describe(ch)
+	i.removed.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (i *Inventory) Collect(ch chan<- prometheus.Metric) {
+	i.count.Collect(ch)
+	i.added.Collect(ch)
+	i.removed.Collect(ch)
+}
@@ -0,0 +1,75 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/go-logr/zapr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestInventory(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(testhelpers.MockOceanAWSClustersClient)
+	mockClient.On("ListClusters", mock.Anything, mock.Anything).
+		Return(&aws.ListClustersOutput{Clusters: testhelpers.OceanClusters("foo", "bar")}, nil).Once()
+	mockClient.On("ListClusters", mock.Anything, mock.Anything).
+		Return(nil, errors.New("unavailable")).Once()
+	mockClient.On("ListClusters", mock.Anything, mock.Anything).
+		Return(&aws.ListClustersOutput{Clusters: testhelpers.OceanClusters("bar", "baz", "qux")}, nil).Once()
+
+	inventory := NewInventory(logger, mockClient, Filter{}, 0)
+	assert.Empty(t, inventory.Clusters())
+
+	assert.NoError(t, inventory.Refresh(ctx))
+	assert.Equal(t, testhelpers.OceanClusters("foo", "bar"), inventory.Clusters())
+
+	assert.Error(t, inventory.Refresh(ctx))
+	assert.Equal(t, testhelpers.OceanClusters("foo", "bar"), inventory.Clusters())
+
+	assert.NoError(t, inventory.Refresh(ctx))
+	assert.Equal(t, testhelpers.OceanClusters("bar", "baz", "qux"), inventory.Clusters())
+
+	expected := `
+        # HELP spotinst_exporter_clusters Number of ocean clusters that metrics are collected for
+        # TYPE spotinst_exporter_clusters gauge
+        spotinst_exporter_clusters 3
+        # HELP spotinst_exporter_clusters_added_total Total number of ocean clusters discovered
+        # TYPE spotinst_exporter_clusters_added_total counter
+        spotinst_exporter_clusters_added_total 4
+        # HELP spotinst_exporter_clusters_removed_total Total number of ocean clusters that disappeared
+        # TYPE spotinst_exporter_clusters_removed_total counter
+        spotinst_exporter_clusters_removed_total 1
+    `
+
+	assert.NoError(t, testutil.CollectAndCompare(inventory, strings.NewReader(expected)))
+	mockClient.AssertExpectations(t)
+}
+
+func TestInventoryRefreshTimeout(t *testing.T) {
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(testhelpers.MockOceanAWSClustersClient)
+	mockClient.On("ListClusters", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(nil, context.DeadlineExceeded).Once()
+
+	inventory := NewInventory(logger, mockClient, Filter{}, 10*time.Millisecond)
+
+	// A hanging API call is given up after the timeout.
+	assert.ErrorIs(t, inventory.Refresh(context.Background()), context.DeadlineExceeded)
+	assert.Empty(t, inventory.Clusters())
+	mockClient.AssertExpectations(t)
+}
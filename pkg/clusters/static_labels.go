@@ -0,0 +1,180 @@
+package clusters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+var (
+	errMissingSelector = errors.New("cluster selector must not be empty")
+	errMissingLabels   = errors.New("at least one label is required")
+)
+
+// StaticLabelRule assigns constant labels to the clusters matching its
+// selector, which is either an Ocean cluster ID or a regular expression
+// matching cluster names.
+type StaticLabelRule struct {
+	id      string
+	pattern *regexp.Regexp
+	names   []string
+	values  []string
+}
+
+// ParseStaticLabelRule parses a rule in the format
+// `selector:name=value[,name=value...]`. The selector is either an Ocean
+// cluster ID, e.g. `o-12345678`, or a regular expression matching cluster
+// names prefixed with `~`, e.g. `~^prod-`. The regular expression must not
+// contain a colon.
+//
+// Returns an error if the input is malformed or contains invalid label names.
+func ParseStaticLabelRule(input string) (StaticLabelRule, error) {
+	var rule StaticLabelRule
+
+	selector, assignments, found := strings.Cut(input, ":")
+	if selector == "" || selector == "~" {
+		return rule, fmt.Errorf("invalid cluster labels %q: %w", input, errMissingSelector)
+	}
+
+	if !found || assignments == "" {
+		return rule, fmt.Errorf("invalid cluster labels %q: %w", input, errMissingLabels)
+	}
+
+	if expr, isPattern := strings.CutPrefix(selector, "~"); isPattern {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return rule, fmt.Errorf("invalid cluster labels %q: %w", input, err)
+		}
+
+		rule.pattern = pattern
+	} else {
+		rule.id = selector
+	}
+
+	for _, assignment := range strings.Split(assignments, ",") {
+		name, value, _ := strings.Cut(assignment, "=")
+		if err := labels.ValidateName(name); err != nil {
+			return rule, fmt.Errorf("invalid cluster labels %q: %w", input, err)
+		}
+
+		rule.names = append(rule.names, name)
+		rule.values = append(rule.values, value)
+	}
+
+	return rule, nil
+}
+
+// Matches returns true if the rule's selector matches cluster.
+func (r StaticLabelRule) Matches(cluster *aws.Cluster) bool {
+	if r.pattern != nil {
+		return r.pattern.MatchString(spotinst.StringValue(cluster.Name))
+	}
+
+	return r.id == spotinst.StringValue(cluster.ID)
+}
+
+// String implements fmt.Stringer.
+func (r StaticLabelRule) String() string {
+	var sb strings.Builder
+
+	if r.pattern != nil {
+		sb.WriteString("~" + r.pattern.String())
+	} else {
+		sb.WriteString(r.id)
+	}
+
+	for i, name := range r.names {
+		if i == 0 {
+			sb.WriteRune(':')
+		} else {
+			sb.WriteRune(',')
+		}
+
+		sb.WriteString(name + "=" + r.values[i])
+	}
+
+	return sb.String()
+}
+
+// StaticLabels is a list of rules assigning constant labels to clusters. It
+// implements the pflag.Value interface, each call to Set appends a rule.
+type StaticLabels []StaticLabelRule
+
+// LabelNames returns the sorted union of the label names of all rules. Every
+// cluster gets a value for each of them.
+func (s StaticLabels) LabelNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, rule := range s {
+		for _, name := range rule.names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LabelValues returns the values of the labels returned by LabelNames for
+// cluster. If multiple matching rules set the same label, the last one wins.
+// Labels not set by any matching rule are empty.
+func (s StaticLabels) LabelValues(cluster *aws.Cluster) []string {
+	values := make(map[string]string)
+
+	for _, rule := range s {
+		if !rule.Matches(cluster) {
+			continue
+		}
+
+		for i, name := range rule.names {
+			values[name] = rule.values[i]
+		}
+	}
+
+	names := s.LabelNames()
+	labelValues := make([]string, 0, len(names))
+
+	for _, name := range names {
+		labelValues = append(labelValues, values[name])
+	}
+
+	return labelValues
+}
+
+// Set implements the pflag.Value interface.
+func (s *StaticLabels) Set(value string) error {
+	rule, err := ParseStaticLabelRule(value)
+	if err != nil {
+		return err
+	}
+
+	*s = append(*s, rule)
+	return nil
+}
+
+// String implements the pflag.Value interface.
+func (s StaticLabels) String() string {
+	rules := make([]string, 0, len(s))
+
+	for _, rule := range s {
+		rules = append(rules, rule.String())
+	}
+
+	return strings.Join(rules, " ")
+}
+
+// Type implements the pflag.Value interface.
+func (s StaticLabels) Type() string {
+	return "selector:name=value"
+}
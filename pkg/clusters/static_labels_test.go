@@ -0,0 +1,66 @@
+package clusters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStaticLabelRule(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expectedErr string
+	}{
+		{input: "o-12345678:env=prod"},
+		{input: "~^prod-:env=prod,region_group=eu"},
+		{input: "o-12345678:empty="},
+		{input: ":env=prod", expectedErr: "cluster selector must not be empty"},
+		{input: "~:env=prod", expectedErr: "cluster selector must not be empty"},
+		{input: "o-12345678", expectedErr: "at least one label is required"},
+		{input: "o-12345678:", expectedErr: "at least one label is required"},
+		{input: "~[a-:env=prod", expectedErr: "error parsing regexp"},
+		{input: "o-12345678:region-group=eu", expectedErr: `invalid Prometheus label name: "region-group"`},
+		{input: "o-12345678:env=prod,", expectedErr: `invalid Prometheus label name: ""`},
+		{input: "o-12345678:namespace=foo", expectedErr: `label name is reserved: "namespace"`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			rule, err := ParseStaticLabelRule(testCase.input)
+			if testCase.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.input, rule.String())
+		})
+	}
+}
+
+func TestStaticLabels(t *testing.T) {
+	var labels StaticLabels
+	require.NoError(t, labels.Set("~^prod-:env=prod,region_group=eu"))
+	require.NoError(t, labels.Set("o-3:env=staging"))
+	require.NoError(t, labels.Set("o-4:team=foo"))
+
+	assert.Equal(t, []string{"env", "region_group", "team"}, labels.LabelNames())
+
+	testCases := []struct {
+		name     string
+		id       string
+		expected []string
+	}{
+		{name: "prod-eu", id: "o-1", expected: []string{"prod", "eu", ""}},
+		{name: "dev", id: "o-2", expected: []string{"", "", ""}},
+		{name: "prod-eu-canary", id: "o-3", expected: []string{"staging", "eu", ""}},
+		{name: "sandbox", id: "o-4", expected: []string{"", "", "foo"}},
+	}
+
+	for _, testCase := range testCases {
+		cluster := taggedCluster(testCase.id, testCase.name, nil)
+		assert.Equal(t, testCase.expected, labels.LabelValues(cluster), testCase.name)
+	}
+}
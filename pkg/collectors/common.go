@@ -1,7 +1,52 @@
 // Package collectors contains Prometheus collectors for Spotinst metrics.
 package collectors
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"context"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/selfmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// ClusterLister is the interface for something that provides the list of
+// Ocean clusters to collect metrics for.
+//
+// It is implemented by *clusters.Inventory and clusters.Static.
+type ClusterLister interface {
+	Clusters() []*aws.Cluster
+}
+
+// ContextCollector is a prometheus.Collector which can collect metrics on
+// behalf of a single scrape, e.g. to stop fetching data from the Spotinst API
+// once the scrape request was cancelled or exceeded its deadline.
+type ContextCollector interface {
+	prometheus.Collector
+
+	// CollectContext is like Collect, but gives up fetching data once ctx is
+	// done.
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+// BindContext returns a prometheus.Collector which collects the metrics of c
+// using ctx, e.g. the context of a scrape request.
+func BindContext(ctx context.Context, c ContextCollector) prometheus.Collector {
+	return &boundCollector{ContextCollector: c, ctx: ctx}
+}
+
+type boundCollector struct {
+	ContextCollector
+	ctx context.Context
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *boundCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(c.ctx, ch)
+}
 
 func collectGaugeValue(
 	ch chan<- prometheus.Metric,
@@ -16,3 +61,291 @@ func collectGaugeValue(
 		labelValues...,
 	)
 }
+
+// Option configures optional behaviour of a collector.
+type Option func(*options)
+
+type options struct {
+	refreshInterval       time.Duration
+	concurrency           int
+	scrapeTimeout         time.Duration
+	refreshTimeout        time.Duration
+	metrics               *selfmetrics.Metrics
+	costWindows           CostWindows
+	location              *time.Location
+	clock                 func() time.Time
+	forecastMethod        ForecastMethod
+	runRateDays           int
+	allocationMode        AllocationMode
+	sharedNamespaces      []string
+	normalizationRules    NormalizationRules
+	workloadsPerNamespace WorkloadLimits
+	workloadsPerCluster   WorkloadLimits
+	clusterTagMappings    labels.Mappings
+	clusterStaticLabels   clusters.StaticLabels
+	labelPrecedence       LabelPrecedence
+	infoLabelMappings     labels.Mappings
+	workloadIndex         *WorkloadIndex
+	costCache             *CostCache
+	projectedSavings      bool
+	savingsCPUWeight      float64
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		concurrency:        1,
+		costWindows:        CostWindows{CostWindowMonthToDate},
+		location:           time.Local,
+		clock:              time.Now,
+		forecastMethod:     ForecastNone,
+		runRateDays:        7,
+		allocationMode:     AllocationNone,
+		normalizationRules: DefaultNormalizationRules,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// scrapeContext returns a context which expires after the configured scrape
+// timeout, if any.
+func (o *options) scrapeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.scrapeTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, o.scrapeTimeout)
+}
+
+// collectContext returns a context for a single Collect call which is done
+// once the scrape context ctx or the collector's context parent is done, or
+// the configured scrape timeout expired.
+func (o *options) collectContext(parent, ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := o.scrapeContext(ctx)
+	stop := context.AfterFunc(parent, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// refreshContext returns a context which expires after the configured
+// refresh timeout, if any.
+func (o *options) refreshContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.refreshTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, o.refreshTimeout)
+}
+
+// now returns the current time in the configured location.
+func (o *options) now() time.Time {
+	return o.clock().In(o.location)
+}
+
+// clusterLabelNames returns the names of the labels identifying a cluster on
+// all of its metrics.
+func (o *options) clusterLabelNames() []string {
+	names := append([]string{"ocean_id", "ocean_name"}, o.clusterTagMappings.LabelNames()...)
+
+	return append(names, o.clusterStaticLabels.LabelNames()...)
+}
+
+// clusterLabelValues returns the values of the clusterLabelNames for cluster.
+func (o *options) clusterLabelValues(cluster *aws.Cluster) []string {
+	values := []string{spotinst.StringValue(cluster.ID), spotinst.StringValue(cluster.Name)}
+	values = append(values, o.clusterTagMappings.LabelValues(clusters.Tags(cluster))...)
+
+	return append(values, o.clusterStaticLabels.LabelValues(cluster)...)
+}
+
+// WithRefreshInterval makes the collector fetch data from the Spotinst API in
+// the background every interval instead of on every scrape. Collect then only
+// serves the last successful snapshot. A zero interval (the default) fetches
+// synchronously during Collect.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.refreshInterval = interval
+	}
+}
+
+// WithConcurrency sets the maximum number of clusters for which data is fetched
+// from the Spotinst API in parallel. Defaults to 1.
+func WithConcurrency(concurrency int) Option {
+	return func(o *options) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithScrapeTimeout limits the time spent fetching data from the Spotinst API
+// during a single scrape, including retries. Zero (the default) means no limit
+// other than the deadline of the scrape context passed to CollectContext.
+func WithScrapeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.scrapeTimeout = timeout
+	}
+}
+
+// WithRefreshTimeout limits the time spent fetching data from the Spotinst API
+// during a single background refresh, including retries. Zero (the default)
+// means no limit. Only used together with WithRefreshInterval.
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.refreshTimeout = timeout
+	}
+}
+
+// WithMetrics makes the collector record metrics about its scrapes and the
+// Spotinst API calls it makes in m.
+func WithMetrics(m *selfmetrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithCostWindows sets the time windows for which costs are collected. Each
+// window is exported as a separate set of metric families. Defaults to the
+// month-to-date window. Only used by the OceanAWSClusterCostsCollector.
+func WithCostWindows(windows ...CostWindow) Option {
+	return func(o *options) {
+		if len(windows) > 0 {
+			o.costWindows = windows
+		}
+	}
+}
+
+// WithLocation sets the time zone used for calculating the boundaries of
+// days and months, e.g. for cost windows. Defaults to time.Local.
+func WithLocation(location *time.Location) Option {
+	return func(o *options) {
+		o.location = location
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time. Mainly
+// useful for testing.
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithCostForecast enables the projection of month-to-date costs to the end of
+// the month using the given method. The run-rate method is based on the costs
+// of the runRateDays full days before the current day. Only used by the
+// OceanAWSClusterCostsCollector.
+func WithCostForecast(method ForecastMethod, runRateDays int) Option {
+	return func(o *options) {
+		o.forecastMethod = method
+		if runRateDays > 0 {
+			o.runRateDays = runRateDays
+		}
+	}
+}
+
+// WithCostAllocation enables the redistribution of the costs of the
+// sharedNamespaces and the unallocated costs of a cluster to the remaining
+// namespaces using the given mode. Only used by the
+// OceanAWSClusterCostsCollector.
+func WithCostAllocation(mode AllocationMode, sharedNamespaces ...string) Option {
+	return func(o *options) {
+		o.allocationMode = mode
+		o.sharedNamespaces = sharedNamespaces
+	}
+}
+
+// WithNormalizationRules sets the ordered rules for normalizing workload names
+// before their costs are aggregated. Defaults to DefaultNormalizationRules if
+// rules is empty. Only used by the cost collectors.
+func WithNormalizationRules(rules NormalizationRules) Option {
+	return func(o *options) {
+		if len(rules) > 0 {
+			o.normalizationRules = rules
+		}
+	}
+}
+
+// WithWorkloadLimits limits the number of workloads per namespace and per
+// cluster for which costs are collected individually. The costs of the
+// remaining, least expensive workloads are summed up per namespace and kind
+// into a series named "__other__". Only used by the cost collectors.
+func WithWorkloadLimits(perNamespace, perCluster WorkloadLimits) Option {
+	return func(o *options) {
+		o.workloadsPerNamespace = perNamespace
+		o.workloadsPerCluster = perCluster
+	}
+}
+
+// WithClusterTagMappings propagates the tags of ocean clusters mapped by
+// mappings as labels onto all metrics of the cluster.
+func WithClusterTagMappings(mappings labels.Mappings) Option {
+	return func(o *options) {
+		o.clusterTagMappings = mappings
+	}
+}
+
+// WithClusterStaticLabels adds the constant labels of the matching rules of
+// staticLabels onto all metrics of a cluster.
+func WithClusterStaticLabels(staticLabels clusters.StaticLabels) Option {
+	return func(o *options) {
+		o.clusterStaticLabels = staticLabels
+	}
+}
+
+// WithLabelPrecedence sets the order in which the mapped labels of workloads
+// are looked up in the labels of the workload and its namespace. By default,
+// only the workload's own labels are used. Only used by the cost collectors.
+func WithLabelPrecedence(precedence LabelPrecedence) Option {
+	return func(o *options) {
+		o.labelPrecedence = precedence
+	}
+}
+
+// WithInfoLabelMappings enables the spotinst_ocean_aws_namespace_labels and
+// spotinst_ocean_aws_workload_labels info metrics carrying the resource
+// labels mapped by mappings. Unlike the label mappings passed to the cost
+// collectors, these labels are not added to the cost metrics. Only used by
+// the OceanAWSClusterCostsCollector.
+func WithInfoLabelMappings(mappings labels.Mappings) Option {
+	return func(o *options) {
+		o.infoLabelMappings = mappings
+	}
+}
+
+// WithWorkloadIndex shares index between the OceanAWSClusterCostsCollector,
+// which records the labels and costs of all workloads from the month-to-date
+// costs, and the OceanAWSResourceSuggestionsCollector, which propagates the
+// labels onto the resource suggestion metrics and, if enabled via
+// WithProjectedSavings, projects the savings of applying the suggestions.
+func WithWorkloadIndex(index *WorkloadIndex) Option {
+	return func(o *options) {
+		o.workloadIndex = index
+	}
+}
+
+// WithCostCache shares cache between the OceanAWSClusterCostsCollector and the
+// OceanAWSClusterDailyCostsCollector, so the costs of closed periods queried
+// by both are only fetched once. By default, every collector uses a cache of
+// its own.
+func WithCostCache(cache *CostCache) Option {
+	return func(o *options) {
+		o.costCache = cache
+	}
+}
+
+// WithProjectedSavings enables the projected savings of applying resource
+// suggestions if enabled is true. cpuWeight is the share (between 0 and 1) of
+// a workload's cost attributed to its CPU requests, the remaining share is
+// attributed to its memory requests. Requires WithWorkloadIndex. Only used by
+// the OceanAWSResourceSuggestionsCollector.
+func WithProjectedSavings(enabled bool, cpuWeight float64) Option {
+	return func(o *options) {
+		o.projectedSavings = enabled
+		o.savingsCPUWeight = cpuWeight
+	}
+}
@@ -0,0 +1,73 @@
+package collectors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsClusterLabels(t *testing.T) {
+	tagMappings, err := labels.ParseMappings("team")
+	require.NoError(t, err)
+
+	var staticLabels clusters.StaticLabels
+	require.NoError(t, staticLabels.Set("foo:env=prod"))
+	require.NoError(t, staticLabels.Set("~^ocean-:region_group=eu"))
+
+	options := newOptions(WithClusterTagMappings(tagMappings), WithClusterStaticLabels(staticLabels))
+
+	assert.Equal(t, []string{"ocean_id", "ocean_name", "team", "env", "region_group"}, options.clusterLabelNames())
+	assert.Equal(t,
+		[]string{"foo", "ocean-foo", "foo-team", "prod", "eu"},
+		options.clusterLabelValues(taggedOceanCluster("foo", "team", "foo-team")),
+	)
+	assert.Equal(t,
+		[]string{"bar", "ocean-bar", "", "", "eu"},
+		options.clusterLabelValues(testhelpers.OceanClusters("bar")[0]),
+	)
+
+	defaults := newOptions()
+
+	assert.Equal(t, []string{"ocean_id", "ocean_name"}, defaults.clusterLabelNames())
+	assert.Equal(t, []string{"bar", "ocean-bar"}, defaults.clusterLabelValues(testhelpers.OceanClusters("bar")[0]))
+}
+
+func TestOptionsCollectContext(t *testing.T) {
+	options := newOptions(WithScrapeTimeout(time.Minute))
+
+	// The deadline of the scrape context takes precedence if it is earlier
+	// than the scrape timeout.
+	scrapeCtx, scrapeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer scrapeCancel()
+
+	ctx, cancel := options.collectContext(context.Background(), scrapeCtx)
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+	cancel()
+	assert.Error(t, ctx.Err())
+
+	// Cancelling the collector's context cancels the collect context.
+	parent, parentCancel := context.WithCancel(context.Background())
+
+	ctx, cancel = options.collectContext(parent, context.Background())
+	defer cancel()
+
+	deadline, ok = ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 100*time.Millisecond)
+
+	parentCancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("collect context not cancelled")
+	}
+}
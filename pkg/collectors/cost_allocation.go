@@ -0,0 +1,113 @@
+package collectors
+
+import (
+	"fmt"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// AllocationMode is the method used to redistribute the costs of shared
+// namespaces and unallocated costs to the remaining namespaces.
+type AllocationMode string
+
+// Supported allocation modes.
+const (
+	// AllocationNone disables the cost allocation.
+	AllocationNone AllocationMode = "none"
+	// AllocationProportional redistributes the costs proportionally to the
+	// costs of the remaining namespaces.
+	AllocationProportional AllocationMode = "proportional"
+	// AllocationEven redistributes the costs evenly to the remaining
+	// namespaces.
+	AllocationEven AllocationMode = "even"
+)
+
+// ParseAllocationMode parses an allocation mode.
+//
+// Returns an error if the mode is unknown.
+func ParseAllocationMode(input string) (AllocationMode, error) {
+	switch mode := AllocationMode(input); mode {
+	case AllocationNone, AllocationProportional, AllocationEven:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown allocation mode %q, must be one of %s, %s, %s", input, AllocationNone, AllocationProportional, AllocationEven)
+	}
+}
+
+// costAllocation redistributes the costs of shared namespaces and the
+// unallocated costs of a cluster to the remaining (tenant) namespaces.
+type costAllocation struct {
+	mode             AllocationMode
+	sharedNamespaces map[string]bool
+}
+
+// newCostAllocation creates a new costAllocation. Returns nil if mode is
+// AllocationNone.
+func newCostAllocation(mode AllocationMode, sharedNamespaces []string) *costAllocation {
+	if mode == "" || mode == AllocationNone {
+		return nil
+	}
+
+	shared := make(map[string]bool, len(sharedNamespaces))
+	for _, namespace := range sharedNamespaces {
+		shared[namespace] = true
+	}
+
+	return &costAllocation{mode: mode, sharedNamespaces: shared}
+}
+
+// allocate returns the allocated costs of the tenant namespaces of cluster in
+// the order of cluster.Namespaces. Shared namespaces are omitted. Proportional
+// allocation falls back to even allocation if the tenant namespaces have no
+// costs.
+func (a *costAllocation) allocate(cluster *mcs.ClusterCost) ([]*mcs.Namespace, []float64) {
+	var tenants []*mcs.Namespace
+	var namespacesCost, sharedCost, tenantsCost float64
+
+	for _, namespace := range cluster.Namespaces {
+		cost := spotinst.Float64Value(namespace.Cost)
+		namespacesCost += cost
+
+		if a.sharedNamespaces[spotinst.StringValue(namespace.Namespace)] {
+			sharedCost += cost
+			continue
+		}
+
+		tenants = append(tenants, namespace)
+		tenantsCost += cost
+	}
+
+	if len(tenants) == 0 {
+		return nil, nil
+	}
+
+	pool := sharedCost + unallocatedCost(spotinst.Float64Value(cluster.TotalCost), namespacesCost)
+	proportional := a.mode == AllocationProportional && tenantsCost > 0
+	allocated := make([]float64, len(tenants))
+
+	for i, namespace := range tenants {
+		cost := spotinst.Float64Value(namespace.Cost)
+
+		if proportional {
+			allocated[i] = cost + pool*cost/tenantsCost
+		} else {
+			allocated[i] = cost + pool/float64(len(tenants))
+		}
+	}
+
+	return tenants, allocated
+}
+
+func newAllocatedNamespaceCostDesc(suffix, helpSuffix string, clusterLabels []string, labelMappings labels.Mappings) *prometheus.Desc {
+	labelNames := append(append([]string{}, clusterLabels...), "namespace")
+
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("spotinst", "ocean_aws", "allocated_namespace_cost"+suffix),
+		"Cost of a namespace including its share of the costs of shared namespaces and unallocated costs"+helpSuffix,
+		append(labelNames, labelMappings.LabelNames()...),
+		nil,
+	)
+}
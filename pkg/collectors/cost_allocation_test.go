@@ -0,0 +1,105 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllocationMode(t *testing.T) {
+	mode, err := ParseAllocationMode("proportional")
+	require.NoError(t, err)
+	assert.Equal(t, AllocationProportional, mode)
+
+	_, err = ParseAllocationMode("weighted")
+	assert.Error(t, err)
+}
+
+func TestNewCostAllocation(t *testing.T) {
+	assert.Nil(t, newCostAllocation(AllocationNone, []string{"kube-system"}))
+	assert.NotNil(t, newCostAllocation(AllocationEven, nil))
+}
+
+func TestCostAllocationAllocate(t *testing.T) {
+	testCases := []struct {
+		name               string
+		mode               AllocationMode
+		shared             []string
+		cluster            *mcs.ClusterCost
+		expectedNamespaces []string
+		expectedCosts      []float64
+	}{
+		{
+			name:   "proportional",
+			mode:   AllocationProportional,
+			shared: []string{"kube-system"},
+			cluster: &mcs.ClusterCost{
+				TotalCost: spotinst.Float64(130),
+				Namespaces: []*mcs.Namespace{
+					namespaceCost("foo", 60),
+					namespaceCost("kube-system", 20),
+					namespaceCost("bar", 20),
+				},
+			},
+			// 20 shared + 30 unallocated, split 3:1.
+			expectedNamespaces: []string{"foo", "bar"},
+			expectedCosts:      []float64{97.5, 32.5},
+		},
+		{
+			name:   "even",
+			mode:   AllocationEven,
+			shared: []string{"kube-system"},
+			cluster: &mcs.ClusterCost{
+				TotalCost: spotinst.Float64(130),
+				Namespaces: []*mcs.Namespace{
+					namespaceCost("foo", 60),
+					namespaceCost("kube-system", 20),
+					namespaceCost("bar", 20),
+				},
+			},
+			expectedNamespaces: []string{"foo", "bar"},
+			expectedCosts:      []float64{85, 45},
+		},
+		{
+			name: "proportional without tenant costs falls back to even",
+			mode: AllocationProportional,
+			cluster: &mcs.ClusterCost{
+				TotalCost: spotinst.Float64(10),
+				Namespaces: []*mcs.Namespace{
+					namespaceCost("foo", 0),
+					namespaceCost("bar", 0),
+				},
+			},
+			expectedNamespaces: []string{"foo", "bar"},
+			expectedCosts:      []float64{5, 5},
+		},
+		{
+			name:   "only shared namespaces",
+			mode:   AllocationEven,
+			shared: []string{"kube-system"},
+			cluster: &mcs.ClusterCost{
+				TotalCost:  spotinst.Float64(10),
+				Namespaces: []*mcs.Namespace{namespaceCost("kube-system", 10)},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			allocation := newCostAllocation(testCase.mode, testCase.shared)
+
+			namespaces, costs := allocation.allocate(testCase.cluster)
+
+			var names []string
+			for _, namespace := range namespaces {
+				names = append(names, spotinst.StringValue(namespace.Namespace))
+			}
+
+			assert.Equal(t, testCase.expectedNamespaces, names)
+			assert.InDeltaSlice(t, testCase.expectedCosts, costs, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package collectors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// closedCostsTTL is the duration for which the costs of closed cost windows
+// are cached. Costs of past days rarely change, but the Spotinst API might
+// still apply corrections shortly after a day ends.
+const closedCostsTTL = time.Hour
+
+type costCacheEntry struct {
+	output    *mcs.ClusterCostOutput
+	fetchedAt time.Time
+}
+
+// CostCache caches the costs of closed cost windows to avoid fetching the
+// same, final values on every scrape. It is safe for concurrent use and can be
+// shared between collectors via WithCostCache.
+type CostCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]costCacheEntry
+}
+
+// NewCostCache creates a new *CostCache.
+func NewCostCache() *CostCache {
+	return newCostCache(closedCostsTTL)
+}
+
+func newCostCache(ttl time.Duration) *CostCache {
+	return &CostCache{
+		ttl:     ttl,
+		entries: make(map[string]costCacheEntry),
+	}
+}
+
+// get returns the cached output for input, if it was fetched less than the
+// TTL before now.
+func (c *CostCache) get(input *mcs.ClusterCostInput, now time.Time) (*mcs.ClusterCostOutput, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[costCacheKey(input)]
+	if !ok || now.Sub(entry.fetchedAt) >= c.ttl {
+		return nil, false
+	}
+
+	return entry.output, true
+}
+
+// put caches output for input and evicts expired entries.
+func (c *CostCache) put(input *mcs.ClusterCostInput, output *mcs.ClusterCostOutput, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[costCacheKey(input)] = costCacheEntry{output: output, fetchedAt: now}
+}
+
+func costCacheKey(input *mcs.ClusterCostInput) string {
+	return spotinst.StringValue(input.ClusterID) + "/" +
+		spotinst.StringValue(input.FromDate) + "/" +
+		spotinst.StringValue(input.ToDate)
+}
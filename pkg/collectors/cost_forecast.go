@@ -0,0 +1,179 @@
+package collectors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// ForecastMethod is the method used to project month-to-date costs to the end
+// of the month.
+type ForecastMethod string
+
+// Supported forecast methods.
+const (
+	// ForecastNone disables cost forecasts.
+	ForecastNone ForecastMethod = "none"
+	// ForecastLinear extrapolates the month-to-date costs linearly based on
+	// the elapsed time of the month, which is raised to minProjectionPeriod.
+	ForecastLinear ForecastMethod = "linear"
+	// ForecastRunRate adds the average daily costs of the most recent full
+	// days for every remaining day of the month to the month-to-date costs.
+	ForecastRunRate ForecastMethod = "run-rate"
+)
+
+// ParseForecastMethod parses a forecast method.
+//
+// Returns an error if the method is unknown.
+func ParseForecastMethod(input string) (ForecastMethod, error) {
+	switch method := ForecastMethod(input); method {
+	case ForecastNone, ForecastLinear, ForecastRunRate:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown forecast method %q, must be one of %s, %s, %s", input, ForecastNone, ForecastLinear, ForecastRunRate)
+	}
+}
+
+// forecaster projects month-to-date costs to the end of the month.
+type forecaster struct {
+	method ForecastMethod
+	// runRateDays is the number of full days before today the run-rate is
+	// based on.
+	runRateDays int
+	// monthlyFactor extrapolates the month-to-date costs to the whole month,
+	// see monthlyCostFactor.
+	monthlyFactor float64
+	// remainingDays is the fractional number of days of the current month
+	// after now.
+	remainingDays float64
+	// normalization is used to match the workloads of the month-to-date and
+	// run-rate periods.
+	normalization NormalizationRules
+}
+
+func newForecaster(method ForecastMethod, runRateDays int, normalization NormalizationRules, now time.Time) *forecaster {
+	_, to := CostWindowMonthToDate.bounds(now)
+
+	return &forecaster{
+		method:        method,
+		runRateDays:   runRateDays,
+		monthlyFactor: monthlyCostFactor(now),
+		remainingDays: to.Sub(now).Hours() / 24,
+		normalization: normalization,
+	}
+}
+
+// runRateBounds returns the start (inclusive) and end (exclusive) day of the
+// period the run-rate is based on.
+func runRateBounds(now time.Time, days int) (from, to time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	return today.AddDate(0, 0, -days), today
+}
+
+// forecast projects the month-to-date cost to the end of the month. recent is
+// the cost of the run-rate period and only used by the run-rate method.
+func (f *forecaster) forecast(monthToDate, recent float64) float64 {
+	switch f.method {
+	case ForecastRunRate:
+		return monthToDate + recent/float64(f.runRateDays)*f.remainingDays
+	default:
+		return monthToDate * f.monthlyFactor
+	}
+}
+
+// forecastClusterCosts returns a copy of the month-to-date costs with all
+// costs replaced by their forecasts. Namespaces and workloads are matched to
+// the costs of the run-rate period by name. Workloads are aggregated first to
+// match recurring workloads with random name suffixes.
+func (f *forecaster) forecastClusterCosts(monthToDate, recent []*mcs.ClusterCost) []*mcs.ClusterCost {
+	var recentTotal float64
+	recentNamespaces := make(map[string]*mcs.Namespace)
+
+	for _, cluster := range recent {
+		recentTotal += spotinst.Float64Value(cluster.TotalCost)
+
+		for _, namespace := range cluster.Namespaces {
+			recentNamespaces[spotinst.StringValue(namespace.Namespace)] = namespace
+		}
+	}
+
+	forecasts := make([]*mcs.ClusterCost, 0, len(monthToDate))
+
+	for _, cluster := range monthToDate {
+		namespaces := make([]*mcs.Namespace, 0, len(cluster.Namespaces))
+
+		for _, namespace := range cluster.Namespaces {
+			namespaces = append(namespaces, f.forecastNamespace(namespace, recentNamespaces[spotinst.StringValue(namespace.Namespace)]))
+		}
+
+		forecasts = append(forecasts, &mcs.ClusterCost{
+			TotalCost:  spotinst.Float64(f.forecast(spotinst.Float64Value(cluster.TotalCost), recentTotal)),
+			Namespaces: namespaces,
+		})
+	}
+
+	return forecasts
+}
+
+func (f *forecaster) forecastNamespace(monthToDate, recent *mcs.Namespace) *mcs.Namespace {
+	if recent == nil {
+		recent = &mcs.Namespace{}
+	}
+
+	return &mcs.Namespace{
+		Namespace:    monthToDate.Namespace,
+		Labels:       monthToDate.Labels,
+		Cost:         spotinst.Float64(f.forecast(spotinst.Float64Value(monthToDate.Cost), spotinst.Float64Value(recent.Cost))),
+		Deployments:  f.forecastResources(monthToDate.Deployments, recent.Deployments, workloadDeployment),
+		DaemonSets:   f.forecastResources(monthToDate.DaemonSets, recent.DaemonSets, workloadDaemonSet),
+		StatefulSets: f.forecastResources(monthToDate.StatefulSets, recent.StatefulSets, workloadStatefulSet),
+		Jobs:         f.forecastResources(monthToDate.Jobs, recent.Jobs, workloadJob),
+	}
+}
+
+// forecastResources forecasts the costs of the resources of the given
+// workload kind. Resources are matched with the run-rate period by their
+// normalized names. The run-rate costs of a normalized name are split between
+// the month-to-date resources sharing that name proportionally to their costs,
+// so the forecasts add up after aggregation.
+func (f *forecaster) forecastResources(monthToDate, recent []*mcs.Resource, kind string) []*mcs.Resource {
+	if len(monthToDate) == 0 {
+		return nil
+	}
+
+	recentCosts := make(map[string]float64, len(recent))
+
+	for _, resource := range recent {
+		recentCosts[f.normalization.normalize(kind, spotinst.StringValue(resource.Name))] += spotinst.Float64Value(resource.Cost)
+	}
+
+	names := make([]string, len(monthToDate))
+	monthToDateCosts := make(map[string]float64, len(monthToDate))
+	counts := make(map[string]int, len(monthToDate))
+
+	for i, resource := range monthToDate {
+		names[i] = f.normalization.normalize(kind, spotinst.StringValue(resource.Name))
+		monthToDateCosts[names[i]] += spotinst.Float64Value(resource.Cost)
+		counts[names[i]]++
+	}
+
+	forecasts := make([]*mcs.Resource, 0, len(monthToDate))
+
+	for i, resource := range monthToDate {
+		cost := spotinst.Float64Value(resource.Cost)
+
+		share := 1 / float64(counts[names[i]])
+		if total := monthToDateCosts[names[i]]; total > 0 {
+			share = cost / total
+		}
+
+		forecast := *resource
+		forecast.Cost = spotinst.Float64(f.forecast(cost, recentCosts[names[i]]*share))
+		forecasts = append(forecasts, &forecast)
+	}
+
+	return forecasts
+}
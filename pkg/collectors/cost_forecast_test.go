@@ -0,0 +1,92 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecaster(t *testing.T) {
+	linearCases := []struct {
+		name     string
+		now      time.Time
+		cost     float64
+		expected float64
+	}{
+		{name: "10 days elapsed", now: date(2024, 3, 11, 0), cost: 100, expected: 310},
+		// The real elapsed time is used from the second day on.
+		{name: "day 2", now: date(2024, 3, 2, 12), cost: 15, expected: 310},
+		// Forecasts in the first hours of the month don't explode, since the
+		// elapsed time is raised to the minimum projection period.
+		{name: "start of month", now: date(2024, 3, 1, 0), cost: 1, expected: 31},
+		{name: "first hours", now: date(2024, 3, 1, 0).Add(10 * time.Minute), cost: 1, expected: 31},
+	}
+
+	for _, testCase := range linearCases {
+		linear := newForecaster(ForecastLinear, 7, DefaultNormalizationRules, testCase.now)
+		assert.InDelta(t, testCase.expected, linear.forecast(testCase.cost, 0), 1e-9, testCase.name)
+	}
+
+	// 10 days of March elapsed, 21 remaining.
+	now := date(2024, 3, 11, 0)
+
+	runRate := newForecaster(ForecastRunRate, 7, DefaultNormalizationRules, now)
+	assert.InDelta(t, 520, runRate.forecast(100, 140), 1e-9)
+
+	from, to := runRateBounds(now.Add(13*time.Hour), 7)
+	assert.Equal(t, "2024-03-04", from.Format("2006-01-02"))
+	assert.Equal(t, "2024-03-11", to.Format("2006-01-02"))
+}
+
+func TestForecastClusterCosts(t *testing.T) {
+	f := newForecaster(ForecastRunRate, 7, DefaultNormalizationRules, date(2024, 3, 11, 0))
+
+	monthToDate := clusterCostOutput(
+		100,
+		namespaceCost(
+			"foo-ns", 60,
+			resourceCost("foo-ns", "foo-deployment", 30),
+			resourceCost("foo-ns", "foo-job-27745697", 10),
+			resourceCost("foo-ns", "foo-job-27746000", 30),
+		),
+		namespaceCost("bar-ns", 40, resourceCost("bar-ns", "bar-deployment", 40)),
+	)
+	recent := clusterCostOutput(
+		70,
+		namespaceCost(
+			"foo-ns", 35,
+			resourceCost("foo-ns", "foo-deployment", 14),
+			resourceCost("foo-ns", "foo-job-27745937", 14),
+		),
+	)
+
+	expected := []*mcs.ClusterCost{
+		clusterCostOutput(
+			310,
+			namespaceCost(
+				"foo-ns", 165,
+				resourceCost("foo-ns", "foo-deployment", 72),
+				// The run-rate costs of the normalized name are split 1:3.
+				resourceCost("foo-ns", "foo-job-27745697", 20.5),
+				resourceCost("foo-ns", "foo-job-27746000", 61.5),
+			),
+			namespaceCost("bar-ns", 40, resourceCost("bar-ns", "bar-deployment", 40)),
+		).ClusterCosts[0],
+	}
+
+	assert.Equal(t, expected, f.forecastClusterCosts(monthToDate.ClusterCosts, recent.ClusterCosts))
+
+	// The input is not modified.
+	assert.Equal(t, "foo-job-27745697", *monthToDate.ClusterCosts[0].Namespaces[0].Deployments[1].Name)
+}
+
+func TestParseForecastMethod(t *testing.T) {
+	method, err := ParseForecastMethod("run-rate")
+	assert.NoError(t, err)
+	assert.Equal(t, ForecastRunRate, method)
+
+	_, err = ParseForecastMethod("magic")
+	assert.Error(t, err)
+}
@@ -0,0 +1,359 @@
+package collectors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// costDescs holds the metric descriptors for a single cost window.
+type costDescs struct {
+	window                   CostWindow
+	clusterCost              *prometheus.Desc
+	clusterUnallocatedCost   *prometheus.Desc
+	namespaceCost            *prometheus.Desc
+	namespaceUnallocatedCost *prometheus.Desc
+	workloadCost             *prometheus.Desc
+	// allocatedNamespaceCost is only set if cost allocation is enabled.
+	allocatedNamespaceCost *prometheus.Desc
+	// namespaceLabels and workloadLabels are only set if info label mappings
+	// are configured.
+	namespaceLabels *prometheus.Desc
+	workloadLabels  *prometheus.Desc
+}
+
+// newCostDescs creates the cluster, namespace and workload cost descriptors.
+// The metric names and help texts are suffixed with suffix and helpSuffix.
+// The cluster costs are labeled with clusterLabels, which are also the first
+// labels of the namespace and workload costs.
+func newCostDescs(suffix, helpSuffix string, clusterLabels []string, labelMappings labels.Mappings) *costDescs {
+	namespaceLabels := append(append([]string{}, clusterLabels...), "namespace")
+	workloadLabels := append(append([]string{}, namespaceLabels...), "name", "workload")
+
+	return &costDescs{
+		clusterCost: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "cluster_cost"+suffix),
+			"Total cost of an ocean cluster"+helpSuffix,
+			clusterLabels,
+			nil,
+		),
+		clusterUnallocatedCost: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "cluster_unallocated_cost"+suffix),
+			"Cost of an ocean cluster not allocated to any namespace"+helpSuffix,
+			clusterLabels,
+			nil,
+		),
+		namespaceCost: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "namespace_cost"+suffix),
+			"Total cost of a namespace"+helpSuffix,
+			append(namespaceLabels, labelMappings.LabelNames()...),
+			nil,
+		),
+		namespaceUnallocatedCost: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "namespace_unallocated_cost"+suffix),
+			"Cost of a namespace not allocated to any workload"+helpSuffix,
+			append(namespaceLabels, labelMappings.LabelNames()...),
+			nil,
+		),
+		workloadCost: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_cost"+suffix),
+			"Total cost of a workload"+helpSuffix,
+			append(workloadLabels, labelMappings.LabelNames()...),
+			nil,
+		),
+	}
+}
+
+func (d *costDescs) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.clusterCost
+	ch <- d.clusterUnallocatedCost
+	ch <- d.namespaceCost
+	ch <- d.namespaceUnallocatedCost
+	ch <- d.workloadCost
+
+	if d.allocatedNamespaceCost != nil {
+		ch <- d.allocatedNamespaceCost
+	}
+
+	if d.namespaceLabels != nil {
+		ch <- d.namespaceLabels
+		ch <- d.workloadLabels
+	}
+}
+
+// costEmitter emits cluster, namespace and workload cost metrics.
+type costEmitter struct {
+	labelMappings labels.Mappings
+	// labelPrecedence determines whether mapped labels missing on a workload
+	// fall back to the labels of its namespace.
+	labelPrecedence LabelPrecedence
+	// infoLabelMappings are the labels propagated onto the namespace and
+	// workload info metrics.
+	infoLabelMappings labels.Mappings
+	normalization     NormalizationRules
+	// workloadsPerNamespace and workloadsPerCluster limit the number of
+	// workloads collected individually.
+	workloadsPerNamespace WorkloadLimits
+	workloadsPerCluster   WorkloadLimits
+	// allocation is nil if cost allocation is disabled.
+	allocation *costAllocation
+}
+
+func (e *costEmitter) collectClusterCosts(
+	ch chan<- prometheus.Metric,
+	descs *costDescs,
+	clusters []*mcs.ClusterCost,
+	clusterLabelValues []string,
+) {
+	for _, cluster := range clusters {
+		totalCost := spotinst.Float64Value(cluster.TotalCost)
+
+		var namespacesCost float64
+		for _, namespace := range cluster.Namespaces {
+			namespacesCost += spotinst.Float64Value(namespace.Cost)
+		}
+
+		collectGaugeValue(ch, descs.clusterCost, totalCost, clusterLabelValues)
+		collectGaugeValue(ch, descs.clusterUnallocatedCost, unallocatedCost(totalCost, namespacesCost), clusterLabelValues)
+
+		workloads := e.collectNamespaceCosts(ch, descs, cluster.Namespaces, clusterLabelValues)
+		e.collectWorkloadCosts(ch, descs, workloads)
+
+		if e.allocation != nil && descs.allocatedNamespaceCost != nil {
+			e.collectAllocatedNamespaceCosts(ch, descs, cluster, clusterLabelValues)
+		}
+	}
+}
+
+func (e *costEmitter) collectAllocatedNamespaceCosts(
+	ch chan<- prometheus.Metric,
+	descs *costDescs,
+	cluster *mcs.ClusterCost,
+	clusterLabelValues []string,
+) {
+	namespaces, allocated := e.allocation.allocate(cluster)
+
+	for i, namespace := range namespaces {
+		labelValues := append(clusterLabelValues[:len(clusterLabelValues):len(clusterLabelValues)], spotinst.StringValue(namespace.Namespace))
+		labelValues = append(labelValues, e.labelMappings.LabelValues(namespace.Labels)...)
+
+		collectGaugeValue(ch, descs.allocatedNamespaceCost, allocated[i], labelValues)
+	}
+}
+
+// collectNamespaceCosts collects the costs of namespaces and returns the
+// workload cost series of the namespaces.
+func (e *costEmitter) collectNamespaceCosts(
+	ch chan<- prometheus.Metric,
+	descs *costDescs,
+	namespaces []*mcs.Namespace,
+	clusterLabelValues []string,
+) []*workloadSeries {
+	workloads := make([]*workloadSeries, 0, 4*len(namespaces))
+
+	for _, namespace := range namespaces {
+		labelValues := append(clusterLabelValues[:len(clusterLabelValues):len(clusterLabelValues)], spotinst.StringValue(namespace.Namespace))
+		namespaceLabelValues := append(labelValues, e.labelMappings.LabelValues(namespace.Labels)...)
+
+		namespaceCost := spotinst.Float64Value(namespace.Cost)
+		workloadsCost := sumResourceCosts(namespace.Deployments) +
+			sumResourceCosts(namespace.DaemonSets) +
+			sumResourceCosts(namespace.StatefulSets) +
+			sumResourceCosts(namespace.Jobs)
+
+		collectGaugeValue(ch, descs.namespaceCost, namespaceCost, namespaceLabelValues)
+		collectGaugeValue(ch, descs.namespaceUnallocatedCost, unallocatedCost(namespaceCost, workloadsCost), namespaceLabelValues)
+		e.collectNamespaceLabels(ch, descs, namespace, labelValues)
+
+		workloads = append(workloads,
+			e.workloadSeries(namespace.Deployments, workloadDeployment, labelValues, namespace.Labels),
+			e.workloadSeries(namespace.DaemonSets, workloadDaemonSet, labelValues, namespace.Labels),
+			e.workloadSeries(namespace.StatefulSets, workloadStatefulSet, labelValues, namespace.Labels),
+			e.workloadSeries(namespace.Jobs, workloadJob, labelValues, namespace.Labels),
+		)
+	}
+
+	return workloads
+}
+
+// workloadSeries returns the cost series of the resources of the given
+// workload kind. The resource names are normalized to avoid high metric
+// cardinality, which might lead to multiple resources with identical label
+// values. The costs of these are summed up.
+func (e *costEmitter) workloadSeries(
+	resources []*mcs.Resource,
+	workloadName string,
+	namespaceLabelValues []string,
+	namespaceLabels map[string]string,
+) *workloadSeries {
+	workloads := &workloadSeries{
+		kind:                 workloadName,
+		namespaceLabelValues: namespaceLabelValues,
+		otherLabelValues:     e.workloadLabelValues(nil, namespaceLabels),
+	}
+
+	for _, resource := range resources {
+		name := e.normalization.normalize(workloadName, spotinst.StringValue(resource.Name))
+
+		labelValues := append(namespaceLabelValues[:len(namespaceLabelValues):len(namespaceLabelValues)], name, workloadName)
+		labelValues = append(labelValues, e.workloadLabelValues(resource.Labels, namespaceLabels)...)
+
+		i := workloads.add(labelValues, spotinst.Float64Value(resource.Cost))
+		if i == len(workloads.infoLabelValues) {
+			workloads.infoLabelValues = append(workloads.infoLabelValues, e.infoLabelValues(resource.Labels, namespaceLabels))
+		}
+	}
+
+	return workloads
+}
+
+// workloadLabelValues returns the values of the mapped labels of a workload,
+// looking them up in the labels of the workload and its namespace in the
+// configured order of precedence.
+func (e *costEmitter) workloadLabelValues(workloadLabels, namespaceLabels map[string]string) []string {
+	return e.labelMappings.LabelValues(e.labelPrecedence.labelMaps(workloadLabels, namespaceLabels)...)
+}
+
+// infoLabelValues returns the values of the info labels of a workload, looked
+// up like workloadLabelValues. Returns nil if no info labels are configured.
+func (e *costEmitter) infoLabelValues(workloadLabels, namespaceLabels map[string]string) []string {
+	if len(e.infoLabelMappings) == 0 {
+		return nil
+	}
+
+	return e.infoLabelMappings.LabelValues(e.labelPrecedence.labelMaps(workloadLabels, namespaceLabels)...)
+}
+
+// collectWorkloadCosts collects the workload costs of a cluster. If workload
+// limits are configured, only the most expensive workloads per namespace and
+// cluster are collected individually, the costs of the remaining ones are
+// summed up per namespace and kind into a series named otherWorkloadName.
+func (e *costEmitter) collectWorkloadCosts(
+	ch chan<- prometheus.Metric,
+	descs *costDescs,
+	workloads []*workloadSeries,
+) {
+	applyWorkloadLimits(workloads, e.workloadsPerNamespace, e.workloadsPerCluster)
+
+	for _, w := range workloads {
+		for i, workload := range w.series {
+			if !w.dropped[i] {
+				collectGaugeValue(ch, descs.workloadCost, workload.value, workload.labelValues)
+			}
+		}
+
+		if cost, ok := w.otherCost(); ok {
+			labelValues := append(w.namespaceLabelValues[:len(w.namespaceLabelValues):len(w.namespaceLabelValues)], otherWorkloadName, w.kind)
+			labelValues = append(labelValues, w.otherLabelValues...)
+
+			collectGaugeValue(ch, descs.workloadCost, cost, labelValues)
+		}
+
+		e.collectWorkloadLabels(ch, descs, w)
+	}
+}
+
+// series is a single metric series.
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// seriesSet sums up the values of series with identical label values. The
+// series are kept in the order they were first added.
+type seriesSet struct {
+	index  map[string]int
+	series []series
+}
+
+// add adds value to the series with the given label values and returns its
+// index.
+func (s *seriesSet) add(labelValues []string, value float64) int {
+	key := strings.Join(labelValues, "\xff")
+
+	if i, ok := s.index[key]; ok {
+		s.series[i].value += value
+		return i
+	}
+
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+
+	s.index[key] = len(s.series)
+	s.series = append(s.series, series{labelValues: labelValues, value: value})
+
+	return len(s.series) - 1
+}
+
+// unallocatedCost returns the part of total that is not allocated to its
+// parts. Rounding differences of the Spotinst API could make it slightly
+// negative, so it is capped at zero.
+func unallocatedCost(total, allocated float64) float64 {
+	return max(total-allocated, 0)
+}
+
+func sumResourceCosts(resources []*mcs.Resource) float64 {
+	var sum float64
+	for _, resource := range resources {
+		sum += spotinst.Float64Value(resource.Cost)
+	}
+
+	return sum
+}
+
+// costFetcher fetches cluster costs. The costs of closed periods are final and
+// therefore served from a cache if possible.
+type costFetcher struct {
+	client      OceanAWSClusterCostsClient
+	closedCosts *CostCache
+}
+
+// newCostFetcher creates a new *costFetcher caching closed costs in cache, or
+// in a cache of its own if cache is nil.
+func newCostFetcher(client OceanAWSClusterCostsClient, cache *CostCache) *costFetcher {
+	if cache == nil {
+		cache = NewCostCache()
+	}
+
+	return &costFetcher{
+		client:      client,
+		closedCosts: cache,
+	}
+}
+
+// getClusterCosts fetches the costs of cluster between the from and to days.
+func (f *costFetcher) getClusterCosts(
+	ctx context.Context,
+	cluster *aws.Cluster,
+	from, to time.Time,
+	closed bool,
+	now time.Time,
+) (*mcs.ClusterCostOutput, error) {
+	input := &mcs.ClusterCostInput{
+		ClusterID: cluster.ControllerClusterID,
+		FromDate:  spotinst.String(from.Format("2006-01-02")),
+		ToDate:    spotinst.String(to.Format("2006-01-02")),
+	}
+
+	if output, ok := f.closedCosts.get(input, now); closed && ok {
+		return output, nil
+	}
+
+	output, err := f.client.GetClusterCosts(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if closed {
+		f.closedCosts.put(input, output, now)
+	}
+
+	return output, nil
+}
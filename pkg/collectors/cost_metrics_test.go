@@ -0,0 +1,24 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesSet(t *testing.T) {
+	var set seriesSet
+	set.add([]string{"foo", "a"}, 1)
+	set.add([]string{"bar", "a"}, 2)
+	set.add([]string{"foo", "a"}, 3)
+	// Label values are not simply concatenated.
+	set.add([]string{"fooa", ""}, 4)
+
+	expected := []series{
+		{labelValues: []string{"foo", "a"}, value: 4},
+		{labelValues: []string{"bar", "a"}, value: 2},
+		{labelValues: []string{"fooa", ""}, value: 4},
+	}
+
+	assert.Equal(t, expected, set.series)
+}
@@ -0,0 +1,169 @@
+package collectors
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CostWindow is a named time range for which Ocean costs are collected.
+type CostWindow string
+
+// Supported cost windows.
+const (
+	// CostWindowMonthToDate covers the current calendar month.
+	CostWindowMonthToDate CostWindow = "month-to-date"
+	// CostWindowToday covers the current day.
+	CostWindowToday CostWindow = "today"
+	// CostWindowYesterday covers the previous day.
+	CostWindowYesterday CostWindow = "yesterday"
+	// CostWindowLast7Days covers the seven full days before the current day.
+	CostWindowLast7Days CostWindow = "last-7-days"
+	// CostWindowPreviousMonth covers the previous calendar month.
+	CostWindowPreviousMonth CostWindow = "previous-month"
+)
+
+var costWindows = []CostWindow{
+	CostWindowMonthToDate,
+	CostWindowToday,
+	CostWindowYesterday,
+	CostWindowLast7Days,
+	CostWindowPreviousMonth,
+}
+
+// bounds returns the start (inclusive) and end (exclusive) day of the window
+// relative to now.
+func (w CostWindow) bounds(now time.Time) (from, to time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	firstDayOfMonth := today.AddDate(0, 0, -today.Day()+1)
+
+	switch w {
+	case CostWindowToday:
+		return today, today.AddDate(0, 0, 1)
+	case CostWindowYesterday:
+		return today.AddDate(0, 0, -1), today
+	case CostWindowLast7Days:
+		return today.AddDate(0, 0, -7), today
+	case CostWindowPreviousMonth:
+		return firstDayOfMonth.AddDate(0, -1, 0), firstDayOfMonth
+	default:
+		return firstDayOfMonth, firstDayOfMonth.AddDate(0, 1, 0)
+	}
+}
+
+// closed returns true if the window lies entirely before the day of now,
+// which means that its costs are final.
+func (w CostWindow) closed(now time.Time) bool {
+	_, to := w.bounds(now)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	return !to.After(today)
+}
+
+// metricSuffix returns the suffix of the metric names for the window. The
+// month-to-date window has no suffix for backwards compatibility.
+func (w CostWindow) metricSuffix() string {
+	if w == CostWindowMonthToDate {
+		return ""
+	}
+
+	return "_" + strings.ReplaceAll(string(w), "-", "_")
+}
+
+// helpSuffix returns the suffix of the metric help texts for the window.
+func (w CostWindow) helpSuffix() string {
+	if w == CostWindowMonthToDate {
+		return ""
+	}
+
+	return " (" + strings.ReplaceAll(string(w), "-", " ") + ")"
+}
+
+// CostWindows is a list of cost windows.
+type CostWindows []CostWindow
+
+// ParseCostWindows parses a comma-separated list of cost windows.
+//
+// Returns an error if the input contains unknown windows.
+func ParseCostWindows(input string) (CostWindows, error) {
+	var windows CostWindows
+
+	for _, name := range strings.Split(input, ",") {
+		window, err := parseCostWindow(name)
+		if err != nil {
+			return nil, err
+		}
+
+		windows = windows.add(window)
+	}
+
+	return windows, nil
+}
+
+func parseCostWindow(name string) (CostWindow, error) {
+	for _, window := range costWindows {
+		if string(window) == name {
+			return window, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown cost window %q, must be one of %s", name, CostWindows(costWindows))
+}
+
+// add appends window if it's not already present.
+func (w CostWindows) add(window CostWindow) CostWindows {
+	for _, existing := range w {
+		if existing == window {
+			return w
+		}
+	}
+
+	return append(w, window)
+}
+
+// Set implements pflag.Value.
+func (w *CostWindows) Set(value string) error {
+	windows, err := ParseCostWindows(value)
+	if err != nil {
+		return err
+	}
+
+	for _, window := range windows {
+		*w = w.add(window)
+	}
+
+	return nil
+}
+
+// String implements pflag.Value.
+func (w CostWindows) String() string {
+	names := make([]string, 0, len(w))
+
+	for _, window := range w {
+		names = append(names, string(window))
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Type implements pflag.Value.
+func (w CostWindows) Type() string {
+	return "cost-windows"
+}
+
+// minProjectionPeriod is the minimum period the month-to-date costs are
+// assumed to cover when extrapolating them to the whole month. It keeps the
+// projections from exploding during the first hours of a month, when only a
+// few hours of possibly lagging cost data are available.
+const minProjectionPeriod = 24 * time.Hour
+
+// monthlyCostFactor returns the factor extrapolating month-to-date costs at
+// now to the whole month based on the elapsed time of the month. During the
+// first day of a month, the elapsed time is raised to minProjectionPeriod,
+// which underestimates the monthly costs rather than overestimating them.
+// Used for both projected savings and linear cost forecasts.
+func monthlyCostFactor(now time.Time) float64 {
+	from, to := CostWindowMonthToDate.bounds(now)
+
+	return float64(to.Sub(from)) / float64(max(now.Sub(from), minProjectionPeriod))
+}
@@ -0,0 +1,89 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostWindowBounds(t *testing.T) {
+	testCases := []struct {
+		window       CostWindow
+		now          time.Time
+		expectedFrom string
+		expectedTo   string
+	}{
+		{window: CostWindowMonthToDate, now: date(2024, 3, 15, 13), expectedFrom: "2024-03-01", expectedTo: "2024-04-01"},
+		{window: CostWindowMonthToDate, now: date(2024, 1, 31, 23), expectedFrom: "2024-01-01", expectedTo: "2024-02-01"},
+		{window: CostWindowMonthToDate, now: date(2024, 12, 1, 0), expectedFrom: "2024-12-01", expectedTo: "2025-01-01"},
+		{window: CostWindowToday, now: date(2024, 2, 29, 13), expectedFrom: "2024-02-29", expectedTo: "2024-03-01"},
+		{window: CostWindowYesterday, now: date(2024, 3, 1, 0), expectedFrom: "2024-02-29", expectedTo: "2024-03-01"},
+		{window: CostWindowLast7Days, now: date(2024, 3, 3, 8), expectedFrom: "2024-02-25", expectedTo: "2024-03-03"},
+		{window: CostWindowPreviousMonth, now: date(2024, 3, 31, 8), expectedFrom: "2024-02-01", expectedTo: "2024-03-01"},
+		{window: CostWindowPreviousMonth, now: date(2024, 1, 1, 0), expectedFrom: "2023-12-01", expectedTo: "2024-01-01"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.window)+" "+testCase.now.String(), func(t *testing.T) {
+			from, to := testCase.window.bounds(testCase.now)
+			assert.Equal(t, testCase.expectedFrom, from.Format("2006-01-02"))
+			assert.Equal(t, testCase.expectedTo, to.Format("2006-01-02"))
+		})
+	}
+}
+
+func TestCostWindowClosed(t *testing.T) {
+	now := date(2024, 3, 1, 0)
+
+	assert.False(t, CostWindowMonthToDate.closed(now))
+	assert.False(t, CostWindowToday.closed(now))
+	assert.True(t, CostWindowYesterday.closed(now))
+	assert.True(t, CostWindowLast7Days.closed(now))
+	assert.True(t, CostWindowPreviousMonth.closed(now))
+}
+
+func TestCostWindows(t *testing.T) {
+	t.Run("valid input", func(t *testing.T) {
+		windows, err := ParseCostWindows("yesterday,month-to-date,yesterday")
+		assert.NoError(t, err)
+		assert.Equal(t, CostWindows{CostWindowYesterday, CostWindowMonthToDate}, windows)
+		assert.Equal(t, "yesterday,month-to-date", windows.String())
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		for _, input := range []string{"", "tomorrow", "today,"} {
+			_, err := ParseCostWindows(input)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		var windows CostWindows
+
+		assert.NoError(t, windows.Set("today"))
+		assert.NoError(t, windows.Set("today,previous-month"))
+		assert.Equal(t, CostWindows{CostWindowToday, CostWindowPreviousMonth}, windows)
+	})
+}
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestMonthlyCostFactor(t *testing.T) {
+	// Half of March has elapsed.
+	assert.InDelta(t, 2, monthlyCostFactor(fixedClock("2024-03-16T12:00:00Z")()), 1e-9)
+
+	// Early in the month the elapsed time is raised to the minimum projection
+	// period.
+	assert.InDelta(t, 31, monthlyCostFactor(fixedClock("2024-03-01T01:00:00Z")()), 1e-9)
+	assert.InDelta(t, 31, monthlyCostFactor(fixedClock("2024-03-02T00:00:00Z")()), 1e-9)
+
+	// Afterwards, the real elapsed time is used.
+	assert.InDelta(t, 31.0/1.5, monthlyCostFactor(fixedClock("2024-03-02T12:00:00Z")()), 1e-9)
+	assert.InDelta(t, 31.0/7, monthlyCostFactor(fixedClock("2024-03-08T00:00:00Z")()), 1e-9)
+
+	// At the end of the month the factor approaches 1.
+	assert.InDelta(t, 1, monthlyCostFactor(time.Date(2024, time.March, 31, 23, 59, 59, 0, time.UTC)), 1e-3)
+}
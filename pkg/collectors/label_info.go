@@ -0,0 +1,77 @@
+package collectors
+
+import (
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+)
+
+// newNamespaceLabelsDesc creates the descriptor of the info metric carrying
+// the labels of a namespace mapped by mappings. Its value is always 1.
+func newNamespaceLabelsDesc(clusterLabels []string, mappings labels.Mappings) *prometheus.Desc {
+	labelNames := append(append([]string{}, clusterLabels...), "namespace")
+
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("spotinst", "ocean_aws", "namespace_labels"),
+		"Labels of a namespace",
+		append(labelNames, mappings.LabelNames()...),
+		nil,
+	)
+}
+
+// newWorkloadLabelsDesc creates the descriptor of the info metric carrying
+// the labels of a workload mapped by mappings. Its value is always 1.
+func newWorkloadLabelsDesc(clusterLabels []string, mappings labels.Mappings) *prometheus.Desc {
+	labelNames := append(append([]string{}, clusterLabels...), "namespace", "name", "workload")
+
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("spotinst", "ocean_aws", "workload_labels"),
+		"Labels of a workload",
+		append(labelNames, mappings.LabelNames()...),
+		nil,
+	)
+}
+
+// collectNamespaceLabels collects the info metric of a namespace if enabled.
+// namespaceLabelValues are the values of the cluster labels and the
+// namespace.
+func (e *costEmitter) collectNamespaceLabels(
+	ch chan<- prometheus.Metric,
+	descs *costDescs,
+	namespace *mcs.Namespace,
+	namespaceLabelValues []string,
+) {
+	if descs.namespaceLabels == nil {
+		return
+	}
+
+	labelValues := append(namespaceLabelValues[:len(namespaceLabelValues):len(namespaceLabelValues)], e.infoLabelMappings.LabelValues(namespace.Labels)...)
+
+	collectGaugeValue(ch, descs.namespaceLabels, 1, labelValues)
+}
+
+// collectWorkloadLabels collects the info metrics of the workloads of w which
+// are collected individually, if enabled. Workloads whose names are identical
+// after normalization share a single info metric carrying the labels of the
+// first one.
+func (e *costEmitter) collectWorkloadLabels(ch chan<- prometheus.Metric, descs *costDescs, w *workloadSeries) {
+	if descs.workloadLabels == nil {
+		return
+	}
+
+	nameIndex := len(w.namespaceLabelValues)
+	seen := make(map[string]bool)
+
+	for i, workload := range w.series {
+		name := workload.labelValues[nameIndex]
+		if w.dropped[i] || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		labelValues := append(workload.labelValues[:nameIndex+2:nameIndex+2], w.infoLabelValues[i]...)
+
+		collectGaugeValue(ch, descs.workloadLabels, 1, labelValues)
+	}
+}
@@ -0,0 +1,108 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errEmptyLabelPrecedence = errors.New("label precedence must contain at least one label source")
+
+// LabelSource is a source of the resource labels propagated onto workload
+// cost metrics.
+type LabelSource string
+
+// Supported label sources.
+const (
+	// LabelSourceWorkload uses the labels of the workload itself.
+	LabelSourceWorkload LabelSource = "workload"
+	// LabelSourceNamespace uses the labels of the workload's namespace.
+	LabelSourceNamespace LabelSource = "namespace"
+)
+
+// LabelPrecedence is the ordered list of sources in which the mapped labels
+// of a workload are looked up. A label missing in a source falls back to the
+// value of the next one. The zero value only uses the workload's own labels.
+type LabelPrecedence []LabelSource
+
+// ParseLabelPrecedence parses a comma-separated list of label sources.
+//
+// Returns an error if the input contains unknown or duplicate sources.
+func ParseLabelPrecedence(input string) (LabelPrecedence, error) {
+	var precedence LabelPrecedence
+
+	for _, name := range strings.Split(input, ",") {
+		switch source := LabelSource(name); source {
+		case LabelSourceWorkload, LabelSourceNamespace:
+			if precedence.contains(source) {
+				return nil, fmt.Errorf("duplicate label source %q", name)
+			}
+
+			precedence = append(precedence, source)
+		case "":
+			return nil, errEmptyLabelPrecedence
+		default:
+			return nil, fmt.Errorf("unknown label source %q, must be one of %s, %s", name, LabelSourceWorkload, LabelSourceNamespace)
+		}
+	}
+
+	return precedence, nil
+}
+
+func (p LabelPrecedence) contains(source LabelSource) bool {
+	for _, existing := range p {
+		if existing == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+// labelMaps returns the given label maps in order of precedence.
+func (p LabelPrecedence) labelMaps(workloadLabels, namespaceLabels map[string]string) []map[string]string {
+	if len(p) == 0 {
+		return []map[string]string{workloadLabels}
+	}
+
+	labelMaps := make([]map[string]string, 0, len(p))
+
+	for _, source := range p {
+		switch source {
+		case LabelSourceWorkload:
+			labelMaps = append(labelMaps, workloadLabels)
+		case LabelSourceNamespace:
+			labelMaps = append(labelMaps, namespaceLabels)
+		}
+	}
+
+	return labelMaps
+}
+
+// Set implements pflag.Value. Unlike other list flags, it replaces the
+// current value, as the order of all sources matters.
+func (p *LabelPrecedence) Set(value string) error {
+	precedence, err := ParseLabelPrecedence(value)
+	if err != nil {
+		return err
+	}
+
+	*p = precedence
+	return nil
+}
+
+// String implements pflag.Value.
+func (p LabelPrecedence) String() string {
+	names := make([]string, 0, len(p))
+
+	for _, source := range p {
+		names = append(names, string(source))
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Type implements pflag.Value.
+func (p LabelPrecedence) Type() string {
+	return "label-sources"
+}
@@ -0,0 +1,44 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelPrecedence(t *testing.T) {
+	precedence, err := ParseLabelPrecedence("namespace,workload")
+	require.NoError(t, err)
+	assert.Equal(t, LabelPrecedence{LabelSourceNamespace, LabelSourceWorkload}, precedence)
+	assert.Equal(t, "namespace,workload", precedence.String())
+
+	for _, input := range []string{"", "workload,", "workload,workload", "cluster"} {
+		_, err := ParseLabelPrecedence(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestLabelPrecedenceSet(t *testing.T) {
+	precedence := LabelPrecedence{LabelSourceWorkload}
+
+	require.NoError(t, precedence.Set("namespace"))
+	assert.Equal(t, LabelPrecedence{LabelSourceNamespace}, precedence)
+
+	assert.Error(t, precedence.Set("namespace,cluster"))
+	assert.Equal(t, LabelPrecedence{LabelSourceNamespace}, precedence)
+}
+
+func TestLabelPrecedenceLabelMaps(t *testing.T) {
+	workloadLabels := map[string]string{"source": "workload"}
+	namespaceLabels := map[string]string{"source": "namespace"}
+
+	assert.Equal(t,
+		[]map[string]string{workloadLabels},
+		LabelPrecedence(nil).labelMaps(workloadLabels, namespaceLabels),
+	)
+	assert.Equal(t,
+		[]map[string]string{namespaceLabels, workloadLabels},
+		LabelPrecedence{LabelSourceNamespace, LabelSourceWorkload}.labelMaps(workloadLabels, namespaceLabels),
+	)
+}
@@ -0,0 +1,166 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Workload kinds as exposed in the "workload" label.
+const (
+	workloadDeployment  = "deployment"
+	workloadDaemonSet   = "daemonset"
+	workloadStatefulSet = "statefulset"
+	workloadJob         = "job"
+)
+
+var workloadKinds = []string{workloadDeployment, workloadDaemonSet, workloadStatefulSet, workloadJob}
+
+// Matches timestamps and UUIDs.
+var uuidRegex = regexp.MustCompile(`[0-9]{8}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// DefaultNormalizationRules removes timestamps and UUIDs from the names of all
+// workload kinds.
+var DefaultNormalizationRules = NormalizationRules{{Regex: uuidRegex}}
+
+// Matches a prefix of a normalization rule which is meant as a workload kind.
+var kindPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var errEmptyMatch = errors.New("regular expression must not match the empty string")
+
+// NormalizationRule replaces all matches of Regex in the names of workloads of
+// the given Kind with Replacement. An empty Kind matches all workload kinds.
+type NormalizationRule struct {
+	Kind        string
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// ParseNormalizationRule parses a rule in the format
+// `[kind:]regex[=>replacement]`, e.g. `job:-[a-z0-9]{5}$` or
+// `deployment:^(spark)-.*=>$1`. The replacement may reference capture groups
+// of the regular expression. If omitted, matches are removed.
+//
+// A prefix consisting only of letters, digits, underscores and hyphens
+// followed by a colon is always parsed as the kind. A regular expression
+// starting with such a prefix must escape the colon, e.g. `foo\:bar`.
+//
+// Returns an error if the kind is unknown, the regular expression is invalid
+// or matches the empty string.
+func ParseNormalizationRule(input string) (NormalizationRule, error) {
+	var rule NormalizationRule
+
+	expr := input
+	if kind, rest, found := strings.Cut(input, ":"); found && kindPrefixRegex.MatchString(kind) {
+		if !isWorkloadKind(kind) {
+			return rule, fmt.Errorf("invalid normalization rule %q: unknown workload kind %q", input, kind)
+		}
+
+		rule.Kind, expr = kind, rest
+	}
+
+	expr, rule.Replacement, _ = strings.Cut(expr, "=>")
+
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, fmt.Errorf("invalid normalization rule %q: %w", input, err)
+	}
+
+	if regex.MatchString("") {
+		return rule, fmt.Errorf("invalid normalization rule %q: %w", input, errEmptyMatch)
+	}
+
+	rule.Regex = regex
+
+	return rule, nil
+}
+
+// String implements fmt.Stringer.
+func (r NormalizationRule) String() string {
+	var sb strings.Builder
+
+	if r.Kind != "" {
+		sb.WriteString(r.Kind + ":")
+	}
+
+	sb.WriteString(r.Regex.String())
+
+	if r.Replacement != "" {
+		sb.WriteString("=>" + r.Replacement)
+	}
+
+	return sb.String()
+}
+
+func isWorkloadKind(kind string) bool {
+	for _, k := range workloadKinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormalizationRules is an ordered list of rules for normalizing workload
+// names. It implements the pflag.Value interface, each call to Set appends a
+// rule. The special value "default" appends the DefaultNormalizationRules.
+type NormalizationRules []NormalizationRule
+
+// normalize applies all rules for the kind in order to name. Hyphens that are
+// left over at the start or end, or doubled, after a rule changed the name
+// are removed. Names equal to the reserved otherWorkloadName are prefixed
+// with an underscore, so they are not merged into the series of the workloads
+// exceeding the workload limits.
+func (r NormalizationRules) normalize(kind, name string) string {
+	normalized := name
+
+	for _, rule := range r {
+		if rule.Kind == "" || rule.Kind == kind {
+			normalized = rule.Regex.ReplaceAllString(normalized, rule.Replacement)
+		}
+	}
+
+	if normalized != name {
+		normalized = strings.Trim(strings.ReplaceAll(normalized, "--", "-"), "-")
+	}
+
+	if normalized == otherWorkloadName {
+		normalized = "_" + normalized
+	}
+
+	return normalized
+}
+
+// Set implements the pflag.Value interface.
+func (r *NormalizationRules) Set(value string) error {
+	if value == "default" {
+		*r = append(*r, DefaultNormalizationRules...)
+		return nil
+	}
+
+	rule, err := ParseNormalizationRule(value)
+	if err != nil {
+		return err
+	}
+
+	*r = append(*r, rule)
+	return nil
+}
+
+// String implements the pflag.Value interface.
+func (r NormalizationRules) String() string {
+	rules := make([]string, 0, len(r))
+
+	for _, rule := range r {
+		rules = append(rules, rule.String())
+	}
+
+	return strings.Join(rules, ",")
+}
+
+// Type implements the pflag.Value interface.
+func (r NormalizationRules) Type() string {
+	return "normalization-rule"
+}
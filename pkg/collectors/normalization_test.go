@@ -0,0 +1,83 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNormalizationRule(t *testing.T) {
+	testCases := []struct {
+		input               string
+		expectedKind        string
+		expectedRegex       string
+		expectedReplacement string
+		expectedErr         string
+	}{
+		{input: "-[a-z0-9]{5}$", expectedRegex: "-[a-z0-9]{5}$"},
+		{input: "job:-[a-z0-9]{5}$", expectedKind: "job", expectedRegex: "-[a-z0-9]{5}$"},
+		{input: "deployment:^(spark)-.*=>$1", expectedKind: "deployment", expectedRegex: "^(spark)-.*", expectedReplacement: "$1"},
+		{input: "(?:foo):bar", expectedRegex: "(?:foo):bar"},
+		{input: `foo\:bar`, expectedRegex: `foo\:bar`},
+		{input: "jobs:^foo-[0-9]+$", expectedErr: `unknown workload kind "jobs"`},
+		{input: "pod:foo", expectedErr: `unknown workload kind "pod"`},
+		{input: "job:(", expectedErr: "error parsing regexp"},
+		{input: "x*", expectedErr: "must not match the empty string"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			rule, err := ParseNormalizationRule(testCase.input)
+			if testCase.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedKind, rule.Kind)
+			assert.Equal(t, testCase.expectedRegex, rule.Regex.String())
+			assert.Equal(t, testCase.expectedReplacement, rule.Replacement)
+			assert.Equal(t, testCase.input, rule.String())
+		})
+	}
+}
+
+func TestNormalizationRulesNormalize(t *testing.T) {
+	var rules NormalizationRules
+	require.NoError(t, rules.Set("default"))
+	require.NoError(t, rules.Set("job:-[a-z0-9]{5}$"))
+	require.NoError(t, rules.Set("deployment:^(spark-driver)-[0-9a-f]+$=>$1"))
+
+	testCases := []struct {
+		kind     string
+		name     string
+		expected string
+	}{
+		{kind: workloadJob, name: "foo-job-27745697", expected: "foo-job"},
+		{kind: workloadJob, name: "workflow-x7k2p", expected: "workflow"},
+		{kind: workloadDeployment, name: "workflow-x7k2p", expected: "workflow-x7k2p"},
+		{kind: workloadDeployment, name: "spark-driver-3fa9c1", expected: "spark-driver"},
+		{kind: workloadDaemonSet, name: "0e11d9c9-bcb9-4c71-b99e-afcecb5e5fc5-qux", expected: "qux"},
+		{kind: workloadStatefulSet, name: "unchanged--name", expected: "unchanged--name"},
+		{kind: workloadDeployment, name: "__other__", expected: "___other__"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, rules.normalize(testCase.kind, testCase.name), testCase.name)
+	}
+
+	// Names normalized to the reserved name are escaped as well.
+	var otherRules NormalizationRules
+	require.NoError(t, otherRules.Set("^legacy-.*=>__other__"))
+	assert.Equal(t, "___other__", otherRules.normalize(workloadJob, "legacy-batch"))
+
+	assert.Equal(t, uuidRegex.String()+",job:-[a-z0-9]{5}$,deployment:^(spark-driver)-[0-9a-f]+$=>$1", rules.String())
+}
+
+func TestNormalizationRulesSetInvalid(t *testing.T) {
+	var rules NormalizationRules
+	assert.Error(t, rules.Set("job:[a-"))
+	assert.Empty(t, rules)
+}
@@ -2,8 +2,7 @@ package collectors
 
 import (
 	"context"
-	"regexp"
-	"strings"
+	"fmt"
 	"time"
 
 	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
@@ -11,9 +10,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spotinst/spotinst-sdk-go/service/mcs"
 	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
-	"github.com/spotinst/spotinst-sdk-go/spotinst"
 )
 
+const clusterCostsCollectorName = "ocean_aws_cluster_costs"
+
 // OceanAWSClusterCostsClient is the interface for fetching Ocean cluster costs.
 //
 // It is implemented by the Spotinst *mcs.ServiceOp client.
@@ -24,174 +24,208 @@ type OceanAWSClusterCostsClient interface {
 // OceanAWSClusterCostsCollector is a prometheus collector for the cost of
 // Spotinst Ocean clusters on AWS.
 type OceanAWSClusterCostsCollector struct {
+	costEmitter
+	*costFetcher
+
 	ctx           context.Context
 	logger        logr.Logger
-	client        OceanAWSClusterCostsClient
-	clusters      []*aws.Cluster
-	labelMappings labels.Mappings
-	clusterCost   *prometheus.Desc
-	namespaceCost *prometheus.Desc
-	workloadCost  *prometheus.Desc
+	clusters      ClusterLister
+	source        clusterSource[clusterCosts]
+	options       *options
+	descs         []*costDescs
+	forecastDescs *costDescs
+	// fetchWindows are the cost windows to fetch. In addition to the
+	// configured cost windows this includes the month-to-date window if
+	// forecasts are enabled or workloads are indexed.
+	fetchWindows CostWindows
+}
+
+// clusterCosts holds the costs of a single cluster.
+type clusterCosts struct {
+	// windows holds the costs per cost window.
+	windows map[CostWindow]*mcs.ClusterCostOutput
+	// runRate holds the costs of the period the run-rate forecast is based
+	// on, if enabled.
+	runRate *mcs.ClusterCostOutput
 }
 
 // NewOceanAWSClusterCostsCollector creates a new OceanAWSClusterCostsCollector
-// for collecting the costs of the provided list of Ocean clusters.
+// for collecting the costs of the Ocean clusters provided by clusters.
 func NewOceanAWSClusterCostsCollector(
 	ctx context.Context,
 	logger logr.Logger,
-	client mcs.Service,
-	clusters []*aws.Cluster,
+	client OceanAWSClusterCostsClient,
+	clusters ClusterLister,
 	labelMappings labels.Mappings,
+	opts ...Option,
 ) *OceanAWSClusterCostsCollector {
+	options := newOptions(opts...)
+
 	collector := &OceanAWSClusterCostsCollector{
-		ctx:           ctx,
-		logger:        logger,
-		client:        client,
-		clusters:      clusters,
-		labelMappings: labelMappings,
-		clusterCost: prometheus.NewDesc(
-			prometheus.BuildFQName("spotinst", "ocean_aws", "cluster_cost"),
-			"Total cost of an ocean cluster",
-			[]string{"ocean_id", "ocean_name"},
-			nil,
-		),
-		namespaceCost: prometheus.NewDesc(
-			prometheus.BuildFQName("spotinst", "ocean_aws", "namespace_cost"),
-			"Total cost of a namespace",
-			append([]string{"ocean_id", "ocean_name", "namespace"}, labelMappings.LabelNames()...),
-			nil,
-		),
-		workloadCost: prometheus.NewDesc(
-			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_cost"),
-			"Total cost of a workload",
-			append([]string{"ocean_id", "ocean_name", "namespace", "name", "workload"}, labelMappings.LabelNames()...),
-			nil,
-		),
+		costEmitter: costEmitter{
+			labelMappings:         labelMappings,
+			labelPrecedence:       options.labelPrecedence,
+			infoLabelMappings:     options.infoLabelMappings,
+			normalization:         options.normalizationRules,
+			workloadsPerNamespace: options.workloadsPerNamespace,
+			workloadsPerCluster:   options.workloadsPerCluster,
+			allocation:            newCostAllocation(options.allocationMode, options.sharedNamespaces),
+		},
+		costFetcher: newCostFetcher(client, options.costCache),
+		ctx:         ctx,
+		logger:      logger,
+		clusters:    clusters,
+		options:     options,
 	}
 
-	return collector
-}
-
-// Describe implements the prometheus.Collector interface.
-func (c *OceanAWSClusterCostsCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.clusterCost
-	ch <- c.namespaceCost
-	ch <- c.workloadCost
-}
-
-// Collect implements the prometheus.Collector interface.
-func (c *OceanAWSClusterCostsCollector) Collect(ch chan<- prometheus.Metric) {
-	now := time.Now()
-	firstDayOfCurrentMonth := now.AddDate(0, 0, -now.Day()+1)
-	firstDayOfNextMonth := now.AddDate(0, 1, -now.Day()+1)
-
-	fromDate := spotinst.String(firstDayOfCurrentMonth.Format("2006-01-02"))
-	toDate := spotinst.String(firstDayOfNextMonth.Format("2006-01-02"))
-
-	for _, cluster := range c.clusters {
-		input := &mcs.ClusterCostInput{
-			ClusterID: cluster.ControllerClusterID,
-			FromDate:  fromDate,
-			ToDate:    toDate,
-		}
+	for _, window := range options.costWindows {
+		descs := newCostDescs(window.metricSuffix(), window.helpSuffix(), options.clusterLabelNames(), labelMappings)
+		descs.window = window
 
-		output, err := c.client.GetClusterCosts(c.ctx, input)
-		if err != nil {
-			clusterID := spotinst.StringValue(cluster.ID)
-			c.logger.Error(err, "failed to fetch cluster costs", "ocean_id", clusterID)
-			continue
+		if collector.allocation != nil {
+			descs.allocatedNamespaceCost = newAllocatedNamespaceCostDesc(window.metricSuffix(), window.helpSuffix(), options.clusterLabelNames(), labelMappings)
 		}
 
-		c.collectClusterCosts(ch, output.ClusterCosts, cluster)
+		collector.descs = append(collector.descs, descs)
+		collector.fetchWindows = collector.fetchWindows.add(window)
 	}
-}
 
-func (c *OceanAWSClusterCostsCollector) collectClusterCosts(
-	ch chan<- prometheus.Metric,
-	clusters []*mcs.ClusterCost,
-	cluster *aws.Cluster,
-) {
-	labelValues := []string{spotinst.StringValue(cluster.ID), spotinst.StringValue(cluster.Name)}
+	if len(options.infoLabelMappings) > 0 {
+		descs := collector.infoDescs()
+		descs.namespaceLabels = newNamespaceLabelsDesc(options.clusterLabelNames(), options.infoLabelMappings)
+		descs.workloadLabels = newWorkloadLabelsDesc(options.clusterLabelNames(), options.infoLabelMappings)
+	}
 
-	for _, cluster := range clusters {
-		collectGaugeValue(ch, c.clusterCost, spotinst.Float64Value(cluster.TotalCost), labelValues)
+	if options.forecastMethod != ForecastNone {
+		collector.forecastDescs = newCostDescs("_forecast", " (end-of-month forecast)", options.clusterLabelNames(), labelMappings)
+		collector.fetchWindows = collector.fetchWindows.add(CostWindowMonthToDate)
+	}
 
-		c.collectNamespaceCosts(ch, cluster.Namespaces, labelValues)
+	if options.workloadIndex != nil {
+		collector.fetchWindows = collector.fetchWindows.add(CostWindowMonthToDate)
 	}
+
+	fetch := fetchFunc[clusterCosts](collector.fetchClusterCosts).instrument(options.metrics, clusterCostsCollectorName)
+	collector.source = newClusterSource(ctx, logger, clusters, options, fetch)
+
+	return collector
 }
 
-func (c *OceanAWSClusterCostsCollector) collectNamespaceCosts(
-	ch chan<- prometheus.Metric,
-	namespaces []*mcs.Namespace,
-	clusterLabelValues []string,
-) {
-	for _, namespace := range namespaces {
-		labelValues := append(clusterLabelValues, spotinst.StringValue(namespace.Namespace))
-		namespaceLabelValues := append(labelValues, c.labelMappings.LabelValues(namespace.Labels)...)
+// infoDescs returns the descriptors of the cost window the info metrics are
+// collected with, which is the month-to-date window if configured and the
+// first configured window otherwise.
+func (c *OceanAWSClusterCostsCollector) infoDescs() *costDescs {
+	for _, descs := range c.descs {
+		if descs.window == CostWindowMonthToDate {
+			return descs
+		}
+	}
+
+	return c.descs[0]
+}
 
-		collectGaugeValue(ch, c.namespaceCost, spotinst.Float64Value(namespace.Cost), namespaceLabelValues)
+// Describe implements the prometheus.Collector interface.
+func (c *OceanAWSClusterCostsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, descs := range c.descs {
+		descs.describe(ch)
+	}
 
-		c.collectWorkloadCosts(ch, namespace.Deployments, "deployment", labelValues)
-		c.collectWorkloadCosts(ch, namespace.DaemonSets, "daemonset", labelValues)
-		c.collectWorkloadCosts(ch, namespace.StatefulSets, "statefulset", labelValues)
-		c.collectWorkloadCosts(ch, namespace.Jobs, "job", labelValues)
+	if c.forecastDescs != nil {
+		c.forecastDescs.describe(ch)
 	}
 }
 
-func (c *OceanAWSClusterCostsCollector) collectWorkloadCosts(
-	ch chan<- prometheus.Metric,
-	resources []*mcs.Resource,
-	workloadName string,
-	namespaceLabelValues []string,
-) {
-	resources = aggregateHighCardinalityResources(resources)
+// Collect implements the prometheus.Collector interface.
+func (c *OceanAWSClusterCostsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(c.ctx, ch)
+}
 
-	for _, resource := range resources {
-		labelValues := append(namespaceLabelValues, spotinst.StringValue(resource.Name), workloadName)
-		labelValues = append(labelValues, c.labelMappings.LabelValues(resource.Labels)...)
+// CollectContext implements the ContextCollector interface.
+func (c *OceanAWSClusterCostsCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	start := time.Now()
+
+	ctx, cancel := c.options.collectContext(c.ctx, ctx)
+	defer cancel()
+
+	clusters := c.clusters.Clusters()
+	results := getAll(ctx, c.source, clusters, c.options.concurrency)
+
+	// Metrics are emitted sequentially in the order of clusters after all
+	// data was fetched, to keep the output deterministic.
+	for i, cluster := range clusters {
+		costs, err := results[i].data, results[i].err
+		if err != nil {
+			logFetchError(c.logger, err, "failed to fetch cluster costs", cluster)
+			continue
+		}
+
+		for _, descs := range c.descs {
+			c.collectClusterCosts(ch, descs, costs.windows[descs.window].ClusterCosts, c.options.clusterLabelValues(cluster))
+		}
 
-		collectGaugeValue(ch, c.workloadCost, spotinst.Float64Value(resource.Cost), labelValues)
+		if c.forecastDescs != nil {
+			c.collectClusterCostForecasts(ch, costs, cluster)
+		}
 	}
+
+	c.options.metrics.RetainClusters(clusterCostsCollectorName, clusters)
+	c.options.workloadIndex.retain(clusters)
+	c.options.metrics.ObserveScrapeDuration(clusterCostsCollectorName, time.Since(start))
 }
 
-// Matches timestamps and UUIDs.
-var uuidRegex = regexp.MustCompile(`[0-9]{8}|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+// fetchClusterCosts fetches the costs of cluster for all cost windows and the
+// run-rate period of forecasts, if enabled.
+func (c *OceanAWSClusterCostsCollector) fetchClusterCosts(
+	ctx context.Context,
+	cluster *aws.Cluster,
+) (clusterCosts, error) {
+	now := c.options.now()
+	costs := clusterCosts{
+		windows: make(map[CostWindow]*mcs.ClusterCostOutput, len(c.fetchWindows)),
+	}
 
-// AggregateHighCardinalityResources removes timestamps and UUIDs from resource
-// names and aggregates the costs for these.
-//
-// UUIDs and timestamps would cause high metric cardinality and will negatively
-// affect performance and storage usage of the metrics engine that will consume
-// the metrics. This function is a best-effort to avoid this.
-func aggregateHighCardinalityResources(resources []*mcs.Resource) []*mcs.Resource {
-	resourceMap := make(map[string]*mcs.Resource, len(resources))
+	for _, window := range c.fetchWindows {
+		from, to := window.bounds(now)
 
-	for _, resource := range resources {
-		oldName := spotinst.StringValue(resource.Name)
+		output, err := c.getClusterCosts(ctx, cluster, from, to, window.closed(now), now)
+		if err != nil {
+			return costs, fmt.Errorf("failed to fetch %s costs: %w", window, err)
+		}
 
-		name := uuidRegex.ReplaceAllString(oldName, "")
+		costs.windows[window] = output
+	}
 
-		if name != oldName {
-			// Remove hyphens that might be left over after removing the timestamps/UUIDs.
-			name = strings.Trim(strings.ReplaceAll(name, "--", "-"), "-")
+	c.options.workloadIndex.update(cluster, costs.windows[CostWindowMonthToDate], now)
 
-			// Sum the costs for existing resources.
-			if existing, ok := resourceMap[name]; ok {
-				resource.Cost = spotinst.Float64(spotinst.Float64Value(resource.Cost) + spotinst.Float64Value(existing.Cost))
-			}
+	if c.options.forecastMethod == ForecastRunRate {
+		from, to := runRateBounds(now, c.options.runRateDays)
 
-			// Update the name.
-			resource.Name = spotinst.String(name)
+		output, err := c.getClusterCosts(ctx, cluster, from, to, true, now)
+		if err != nil {
+			return costs, fmt.Errorf("failed to fetch run-rate costs: %w", err)
 		}
 
-		resourceMap[name] = resource
+		costs.runRate = output
 	}
 
-	cleaned := make([]*mcs.Resource, 0, len(resourceMap))
+	return costs, nil
+}
+
+// collectClusterCostForecasts collects the projected end-of-month costs based
+// on the month-to-date costs.
+func (c *OceanAWSClusterCostsCollector) collectClusterCostForecasts(
+	ch chan<- prometheus.Metric,
+	costs clusterCosts,
+	cluster *aws.Cluster,
+) {
+	f := newForecaster(c.options.forecastMethod, c.options.runRateDays, c.normalization, c.options.now())
 
-	for _, resource := range resourceMap {
-		cleaned = append(cleaned, resource)
+	var recent []*mcs.ClusterCost
+	if costs.runRate != nil {
+		recent = costs.runRate.ClusterCosts
 	}
 
-	return cleaned
+	forecasts := f.forecastClusterCosts(costs.windows[CostWindowMonthToDate].ClusterCosts, recent)
+
+	c.collectClusterCosts(ch, c.forecastDescs, forecasts, c.options.clusterLabelValues(cluster))
 }
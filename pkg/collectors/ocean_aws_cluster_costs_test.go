@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
 	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
 	"github.com/go-logr/zapr"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -43,6 +45,9 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
 		expected      string
 		labelMappings labels.Mappings
 		clusters      []*aws.Cluster
+		options       []Option
+		// metricNames restricts the comparison to the given metrics, if set.
+		metricNames []string
 	}{
 		{
 			name: "no cluster, no output",
@@ -59,7 +64,7 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
 				mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, errors.New("nonexistent"))
 				return mockClient
 			},
-			clusters: oceanClusters("nonexistent"),
+			clusters: testhelpers.OceanClusters("nonexistent"),
 		},
 		{
 			name: "one cluster",
@@ -74,7 +79,7 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
 				mockClient.On("GetClusterCosts", mock.Anything, input).Return(output, nil)
 				return mockClient
 			},
-			clusters: oceanClusters("foo"),
+			clusters: testhelpers.OceanClusters("foo"),
 			expected: `
                 # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
                 # TYPE spotinst_ocean_aws_cluster_cost gauge
@@ -85,6 +90,12 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
                 # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
                 # TYPE spotinst_ocean_aws_workload_cost gauge
                 spotinst_ocean_aws_workload_cost{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 180
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost Cost of a namespace not allocated to any workload
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost gauge
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 10
             `,
 		},
 		{
@@ -117,7 +128,7 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
 				mockClient.On("GetClusterCosts", mock.Anything, input).Return(output, nil)
 				return mockClient
 			},
-			clusters: oceanClusters("foo"),
+			clusters: testhelpers.OceanClusters("foo"),
 			labelMappings: func() labels.Mappings {
 				mappings, _ := labels.ParseMappings("team,app.kubernetes.io/name=app")
 				return mappings
@@ -134,6 +145,517 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
                 # TYPE spotinst_ocean_aws_workload_cost gauge
                 spotinst_ocean_aws_workload_cost{app="foo",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 180
                 spotinst_ocean_aws_workload_cost{app="",name="other-deployment",namespace="other-ns",ocean_id="foo",ocean_name="ocean-foo",team="other-team",workload="deployment"} 181
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 0
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost Cost of a namespace not allocated to any workload
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost gauge
+                spotinst_ocean_aws_namespace_unallocated_cost{app="",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team"} 10
+                spotinst_ocean_aws_namespace_unallocated_cost{app="",namespace="other-ns",ocean_id="foo",ocean_name="ocean-foo",team=""} 10
+            `,
+		},
+		{
+			name: "unallocated costs",
+			client: func() OceanAWSClusterCostsClient {
+				namespace := namespaceCost("foo-ns", 60, resourceCost("foo-ns", "foo-deployment", 20))
+				namespace.Jobs = []*mcs.Resource{resourceCost("foo-ns", "foo-job", 15)}
+
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespace,
+					namespaceCost("bar-ns", 10),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{ocean_id="foo",ocean_name="ocean-foo"} 100
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 30
+                # HELP spotinst_ocean_aws_namespace_cost Total cost of a namespace
+                # TYPE spotinst_ocean_aws_namespace_cost gauge
+                spotinst_ocean_aws_namespace_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 60
+                spotinst_ocean_aws_namespace_cost{namespace="bar-ns",ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost Cost of a namespace not allocated to any workload
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost gauge
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 25
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="bar-ns",ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 20
+                spotinst_ocean_aws_workload_cost{name="foo-job",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 15
+            `,
+		},
+		{
+			name: "proportional cost allocation",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCost("foo-ns", 60),
+					namespaceCost("kube-system", 20),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options:  []Option{WithCostAllocation(AllocationProportional, "kube-system")},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{ocean_id="foo",ocean_name="ocean-foo"} 100
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 20
+                # HELP spotinst_ocean_aws_namespace_cost Total cost of a namespace
+                # TYPE spotinst_ocean_aws_namespace_cost gauge
+                spotinst_ocean_aws_namespace_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 60
+                spotinst_ocean_aws_namespace_cost{namespace="kube-system",ocean_id="foo",ocean_name="ocean-foo"} 20
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost Cost of a namespace not allocated to any workload
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost gauge
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 60
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="kube-system",ocean_id="foo",ocean_name="ocean-foo"} 20
+                # HELP spotinst_ocean_aws_allocated_namespace_cost Cost of a namespace including its share of the costs of shared namespaces and unallocated costs
+                # TYPE spotinst_ocean_aws_allocated_namespace_cost gauge
+                spotinst_ocean_aws_allocated_namespace_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 100
+            `,
+		},
+		{
+			name: "aggregate high cardinality workloads",
+			client: func() OceanAWSClusterCostsClient {
+				namespace := namespaceCost("foo-ns", 58)
+				namespace.Jobs = []*mcs.Resource{
+					resourceCost("foo-ns", "foo", 1),
+					resourceCost("foo-ns", "foo-job-27745697", 1),
+					resourceCost("foo-ns", "baz-job-0e6b40aa-ffa2-4288-80ba-891fbad4b0ba", 20),
+					resourceCost("foo-ns", "foo-job-27745937", 2),
+					resourceCost("foo-ns", "baz-job-0e11d9c9-bcb9-4c71-b99e-afcecb5e5fc5", 10),
+					resourceCost("foo-ns", "bar", 3),
+					resourceCost("foo-ns", "0e11d9c9-bcb9-4c71-b99e-afcecb5e5fc5-qux-job", 5),
+					resourceCost("foo-ns", "0e6b40aa-ffa2-4288-80ba-891fbad4b0ba-qux-job", 7),
+					resourceCost("foo-ns", "27745697-bam-job", 3),
+					resourceCost("foo-ns", "27745937-bam-job", 4),
+				}
+
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(58, namespace), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{name="foo",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 1
+                spotinst_ocean_aws_workload_cost{name="foo-job",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 3
+                spotinst_ocean_aws_workload_cost{name="bar",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 3
+                spotinst_ocean_aws_workload_cost{name="baz-job",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 30
+                spotinst_ocean_aws_workload_cost{name="qux-job",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 12
+                spotinst_ocean_aws_workload_cost{name="bam-job",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="job"} 7
+            `,
+			metricNames: []string{"spotinst_ocean_aws_workload_cost"},
+		},
+		{
+			name: "workloads with identical label values",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCost(
+						"foo-ns", 100,
+						resourceCostLabels("foo-ns", "foo-27745697", 10, map[string]string{"team": "a"}),
+						resourceCostLabels("foo-ns", "foo-27745937", 20, map[string]string{"team": "b"}),
+						resourceCostLabels("foo-ns", "foo-27746177", 30, map[string]string{"team": "a"}),
+						resourceCostLabels("foo-ns", "foo", 40, map[string]string{"team": "a", "other": "x"}),
+					),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team")
+				return mappings
+			}(),
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{name="foo",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="a",workload="deployment"} 80
+                spotinst_ocean_aws_workload_cost{name="foo",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="b",workload="deployment"} 20
+            `,
+			metricNames: []string{"spotinst_ocean_aws_workload_cost"},
+		},
+		{
+			name: "workload limits",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCost(
+						"foo-ns", 60,
+						resourceCostLabels("foo-ns", "foo-a", 10, map[string]string{"team": "a"}),
+						resourceCostLabels("foo-ns", "foo-b", 30, map[string]string{"team": "b"}),
+						resourceCostLabels("foo-ns", "foo-c", 20, map[string]string{"team": "c"}),
+					),
+					namespaceCost(
+						"bar-ns", 40,
+						resourceCost("bar-ns", "bar-a", 25),
+						resourceCost("bar-ns", "bar-b", 15),
+					),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team")
+				return mappings
+			}(),
+			options: []Option{WithWorkloadLimits(WorkloadLimits{"": 2}, WorkloadLimits{"deployment": 3})},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{name="foo-b",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="b",workload="deployment"} 30
+                spotinst_ocean_aws_workload_cost{name="foo-c",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="c",workload="deployment"} 20
+                spotinst_ocean_aws_workload_cost{name="__other__",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="",workload="deployment"} 10
+                spotinst_ocean_aws_workload_cost{name="bar-a",namespace="bar-ns",ocean_id="foo",ocean_name="ocean-foo",team="",workload="deployment"} 25
+                spotinst_ocean_aws_workload_cost{name="__other__",namespace="bar-ns",ocean_id="foo",ocean_name="ocean-foo",team="",workload="deployment"} 15
+            `,
+			metricNames: []string{"spotinst_ocean_aws_workload_cost"},
+		},
+		{
+			name: "namespace label fallback",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCostLabels(
+						"foo-ns", 60,
+						map[string]string{"team": "foo-team", "env": "prod"},
+						resourceCostLabels("foo-ns", "foo-a", 30, map[string]string{"team": "a"}),
+						resourceCost("foo-ns", "foo-b", 20),
+						resourceCost("foo-ns", "foo-c", 10),
+					),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team,env")
+				return mappings
+			}(),
+			options: []Option{
+				WithLabelPrecedence(LabelPrecedence{LabelSourceWorkload, LabelSourceNamespace}),
+				WithWorkloadLimits(WorkloadLimits{"": 2}, nil),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{env="prod",name="foo-a",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="a",workload="deployment"} 30
+                spotinst_ocean_aws_workload_cost{env="prod",name="foo-b",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 20
+                spotinst_ocean_aws_workload_cost{env="prod",name="__other__",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 10
+            `,
+			metricNames: []string{"spotinst_ocean_aws_workload_cost"},
+		},
+		{
+			name: "namespace label precedence",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCostLabels(
+						"foo-ns", 60,
+						map[string]string{"team": "foo-team"},
+						resourceCostLabels("foo-ns", "foo-a", 30, map[string]string{"team": "a", "env": "prod"}),
+					),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team,env")
+				return mappings
+			}(),
+			options: []Option{WithLabelPrecedence(LabelPrecedence{LabelSourceNamespace, LabelSourceWorkload})},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{env="prod",name="foo-a",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 30
+            `,
+			metricNames: []string{"spotinst_ocean_aws_workload_cost"},
+		},
+		{
+			name: "info labels",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					100,
+					namespaceCostLabels(
+						"foo-ns", 60,
+						map[string]string{"team": "foo-team"},
+						resourceCostLabels("foo-ns", "foo-a", 30, map[string]string{"team": "a", "app": "foo"}),
+						resourceCostLabels("foo-ns", "foo-b-28493760", 5, map[string]string{"team": "b", "app": "bar"}),
+						resourceCostLabels("foo-ns", "foo-b-28493761", 15, map[string]string{"team": "c", "app": "bar"}),
+						resourceCost("foo-ns", "foo-c", 10),
+					),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("app")
+				return mappings
+			}(),
+			options: []Option{
+				WithInfoLabelMappings(func() labels.Mappings {
+					mappings, _ := labels.ParseMappings("team")
+					return mappings
+				}()),
+				WithWorkloadLimits(WorkloadLimits{"": 2}, nil),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_namespace_labels Labels of a namespace
+                # TYPE spotinst_ocean_aws_namespace_labels gauge
+                spotinst_ocean_aws_namespace_labels{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team"} 1
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{app="foo",name="foo-a",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 30
+                spotinst_ocean_aws_workload_cost{app="bar",name="foo-b",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 20
+                spotinst_ocean_aws_workload_cost{app="",name="__other__",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 10
+                # HELP spotinst_ocean_aws_workload_labels Labels of a workload
+                # TYPE spotinst_ocean_aws_workload_labels gauge
+                spotinst_ocean_aws_workload_labels{name="foo-a",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="a",workload="deployment"} 1
+                spotinst_ocean_aws_workload_labels{name="foo-b",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="b",workload="deployment"} 1
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_namespace_labels",
+				"spotinst_ocean_aws_workload_cost",
+				"spotinst_ocean_aws_workload_labels",
+			},
+		},
+		{
+			name: "cluster tag labels",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					200,
+					namespaceCost("foo-ns", 190, resourceCost("foo-ns", "foo-deployment", 180)),
+				), nil)
+				return mockClient
+			},
+			clusters: []*aws.Cluster{taggedOceanCluster("foo", "environment", "prod", "cost-center", "cc-1")},
+			options: []Option{WithClusterTagMappings(func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("environment,cost-center=cost_center,team")
+				return mappings
+			}())},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{cost_center="cc-1",environment="prod",ocean_id="foo",ocean_name="ocean-foo",team=""} 200
+                # HELP spotinst_ocean_aws_namespace_cost Total cost of a namespace
+                # TYPE spotinst_ocean_aws_namespace_cost gauge
+                spotinst_ocean_aws_namespace_cost{cost_center="cc-1",environment="prod",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team=""} 190
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{cost_center="cc-1",environment="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="",workload="deployment"} 180
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_cluster_cost",
+				"spotinst_ocean_aws_namespace_cost",
+				"spotinst_ocean_aws_workload_cost",
+			},
+		},
+		{
+			name: "static cluster labels",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					200,
+					namespaceCost("foo-ns", 190, resourceCost("foo-ns", "foo-deployment", 180)),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{WithClusterStaticLabels(func() clusters.StaticLabels {
+				var staticLabels clusters.StaticLabels
+				_ = staticLabels.Set("~^ocean-:env=prod,region_group=eu")
+				return staticLabels
+			}())},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{env="prod",ocean_id="foo",ocean_name="ocean-foo",region_group="eu"} 200
+                # HELP spotinst_ocean_aws_namespace_cost Total cost of a namespace
+                # TYPE spotinst_ocean_aws_namespace_cost gauge
+                spotinst_ocean_aws_namespace_cost{env="prod",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",region_group="eu"} 190
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",region_group="eu",workload="deployment"} 180
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_cluster_cost",
+				"spotinst_ocean_aws_namespace_cost",
+				"spotinst_ocean_aws_workload_cost",
+			},
+		},
+		{
+			name: "multiple cost windows",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+					200,
+					namespaceCost("foo-ns", 190, resourceCost("foo-ns", "foo-deployment", 180)),
+				), nil)
+				mockClient.On("GetClusterCosts", mock.Anything, clusterCostInputWindow("foo", CostWindowYesterday)).Return(clusterCostOutput(
+					20,
+					namespaceCost("foo-ns", 19, resourceCost("foo-ns", "foo-deployment", 18)),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options:  []Option{WithCostWindows(CostWindowMonthToDate, CostWindowYesterday)},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{ocean_id="foo",ocean_name="ocean-foo"} 200
+                # HELP spotinst_ocean_aws_cluster_cost_yesterday Total cost of an ocean cluster (yesterday)
+                # TYPE spotinst_ocean_aws_cluster_cost_yesterday gauge
+                spotinst_ocean_aws_cluster_cost_yesterday{ocean_id="foo",ocean_name="ocean-foo"} 20
+                # HELP spotinst_ocean_aws_namespace_cost Total cost of a namespace
+                # TYPE spotinst_ocean_aws_namespace_cost gauge
+                spotinst_ocean_aws_namespace_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 190
+                # HELP spotinst_ocean_aws_namespace_cost_yesterday Total cost of a namespace (yesterday)
+                # TYPE spotinst_ocean_aws_namespace_cost_yesterday gauge
+                spotinst_ocean_aws_namespace_cost_yesterday{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 19
+                # HELP spotinst_ocean_aws_workload_cost Total cost of a workload
+                # TYPE spotinst_ocean_aws_workload_cost gauge
+                spotinst_ocean_aws_workload_cost{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 180
+                # HELP spotinst_ocean_aws_workload_cost_yesterday Total cost of a workload (yesterday)
+                # TYPE spotinst_ocean_aws_workload_cost_yesterday gauge
+                spotinst_ocean_aws_workload_cost_yesterday{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 18
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost_yesterday Cost of an ocean cluster not allocated to any namespace (yesterday)
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost_yesterday gauge
+                spotinst_ocean_aws_cluster_unallocated_cost_yesterday{ocean_id="foo",ocean_name="ocean-foo"} 1
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost Cost of a namespace not allocated to any workload
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost gauge
+                spotinst_ocean_aws_namespace_unallocated_cost{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost_yesterday Cost of a namespace not allocated to any workload (yesterday)
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost_yesterday gauge
+                spotinst_ocean_aws_namespace_unallocated_cost_yesterday{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 1
+            `,
+		},
+		{
+			name: "month boundary in configured time zone",
+			client: func() OceanAWSClusterCostsClient {
+				input := &mcs.ClusterCostInput{
+					ClusterID: spotinst.String("foo"),
+					FromDate:  spotinst.String("2024-03-01"),
+					ToDate:    spotinst.String("2024-04-01"),
+				}
+
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, input).Return(clusterCostOutput(2), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{
+				// 2024-03-01 00:30 in Europe/Berlin.
+				WithClock(fixedClock("2024-02-29T23:30:00Z")),
+				WithLocation(mustLoadLocation("Europe/Berlin")),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{ocean_id="foo",ocean_name="ocean-foo"} 2
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 2
+            `,
+		},
+		{
+			name: "month boundary in UTC",
+			client: func() OceanAWSClusterCostsClient {
+				input := &mcs.ClusterCostInput{
+					ClusterID: spotinst.String("foo"),
+					FromDate:  spotinst.String("2024-02-01"),
+					ToDate:    spotinst.String("2024-03-01"),
+				}
+
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, input).Return(clusterCostOutput(200), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{
+				WithClock(fixedClock("2024-02-29T23:30:00Z")),
+				WithLocation(time.UTC),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost Total cost of an ocean cluster
+                # TYPE spotinst_ocean_aws_cluster_cost gauge
+                spotinst_ocean_aws_cluster_cost{ocean_id="foo",ocean_name="ocean-foo"} 200
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost Cost of an ocean cluster not allocated to any namespace
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost gauge
+                spotinst_ocean_aws_cluster_unallocated_cost{ocean_id="foo",ocean_name="ocean-foo"} 200
+            `,
+		},
+		{
+			name: "linear forecast",
+			client: func() OceanAWSClusterCostsClient {
+				input := &mcs.ClusterCostInput{
+					ClusterID: spotinst.String("foo"),
+					FromDate:  spotinst.String("2024-03-01"),
+					ToDate:    spotinst.String("2024-04-01"),
+				}
+				output := clusterCostOutput(
+					100,
+					namespaceCost("foo-ns", 60, resourceCost("foo-ns", "foo-deployment", 30)),
+				)
+
+				yesterday := &mcs.ClusterCostInput{
+					ClusterID: spotinst.String("foo"),
+					FromDate:  spotinst.String("2024-03-10"),
+					ToDate:    spotinst.String("2024-03-11"),
+				}
+
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, input).Return(output, nil)
+				mockClient.On("GetClusterCosts", mock.Anything, yesterday).Return(clusterCostOutput(10), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{
+				// 10 of 31 days elapsed.
+				WithClock(fixedClock("2024-03-11T00:00:00Z")),
+				WithLocation(time.UTC),
+				WithCostWindows(CostWindowYesterday),
+				WithCostForecast(ForecastLinear, 0),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_cluster_cost_yesterday Total cost of an ocean cluster (yesterday)
+                # TYPE spotinst_ocean_aws_cluster_cost_yesterday gauge
+                spotinst_ocean_aws_cluster_cost_yesterday{ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_cluster_cost_forecast Total cost of an ocean cluster (end-of-month forecast)
+                # TYPE spotinst_ocean_aws_cluster_cost_forecast gauge
+                spotinst_ocean_aws_cluster_cost_forecast{ocean_id="foo",ocean_name="ocean-foo"} 310
+                # HELP spotinst_ocean_aws_namespace_cost_forecast Total cost of a namespace (end-of-month forecast)
+                # TYPE spotinst_ocean_aws_namespace_cost_forecast gauge
+                spotinst_ocean_aws_namespace_cost_forecast{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 186
+                # HELP spotinst_ocean_aws_workload_cost_forecast Total cost of a workload (end-of-month forecast)
+                # TYPE spotinst_ocean_aws_workload_cost_forecast gauge
+                spotinst_ocean_aws_workload_cost_forecast{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 93
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost_yesterday Cost of an ocean cluster not allocated to any namespace (yesterday)
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost_yesterday gauge
+                spotinst_ocean_aws_cluster_unallocated_cost_yesterday{ocean_id="foo",ocean_name="ocean-foo"} 10
+                # HELP spotinst_ocean_aws_cluster_unallocated_cost_forecast Cost of an ocean cluster not allocated to any namespace (end-of-month forecast)
+                # TYPE spotinst_ocean_aws_cluster_unallocated_cost_forecast gauge
+                spotinst_ocean_aws_cluster_unallocated_cost_forecast{ocean_id="foo",ocean_name="ocean-foo"} 124
+                # HELP spotinst_ocean_aws_namespace_unallocated_cost_forecast Cost of a namespace not allocated to any workload (end-of-month forecast)
+                # TYPE spotinst_ocean_aws_namespace_unallocated_cost_forecast gauge
+                spotinst_ocean_aws_namespace_unallocated_cost_forecast{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 93
             `,
 		},
 	}
@@ -143,25 +665,83 @@ func TestOceanAWSClusterCostsCollector(t *testing.T) {
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			ctx := context.Background()
-			collector := NewOceanAWSClusterCostsCollector(ctx, logger, testCase.client(), testCase.clusters, testCase.labelMappings)
+			collector := NewOceanAWSClusterCostsCollector(ctx, logger, testCase.client(), clusters.Static(testCase.clusters), testCase.labelMappings, testCase.options...)
 
-			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(testCase.expected)))
+			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(testCase.expected), testCase.metricNames...))
 		})
 	}
 }
 
-func oceanClusters(clusterIDs ...string) []*aws.Cluster {
-	clusters := make([]*aws.Cluster, 0, len(clusterIDs))
+func TestOceanAWSClusterCostsCollectorClosedWindows(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
 
-	for _, id := range clusterIDs {
-		clusters = append(clusters, &aws.Cluster{
-			ID:                  spotinst.String(id),
-			ControllerClusterID: spotinst.String(id),
-			Name:                spotinst.String("ocean-" + id),
+	currentMonth := &mcs.ClusterCostInput{
+		ClusterID: spotinst.String("foo"),
+		FromDate:  spotinst.String("2024-03-01"),
+		ToDate:    spotinst.String("2024-04-01"),
+	}
+	previousMonth := &mcs.ClusterCostInput{
+		ClusterID: spotinst.String("foo"),
+		FromDate:  spotinst.String("2024-02-01"),
+		ToDate:    spotinst.String("2024-03-01"),
+	}
+
+	mockClient := new(mockOceanAWSClusterCostsClient)
+	mockClient.On("GetClusterCosts", mock.Anything, currentMonth).Return(clusterCostOutput(
+		20,
+		namespaceCost("foo-ns", 19, resourceCost("foo-ns", "foo-deployment", 18)),
+	), nil).Twice()
+	mockClient.On("GetClusterCosts", mock.Anything, previousMonth).Return(clusterCostOutput(
+		200,
+		namespaceCost("foo-ns", 190, resourceCost("foo-ns", "foo-deployment", 180)),
+	), nil).Once()
+
+	collector := NewOceanAWSClusterCostsCollector(
+		ctx, logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil,
+		WithCostWindows(CostWindowMonthToDate, CostWindowPreviousMonth),
+		WithClock(fixedClock("2024-03-10T12:00:00Z")),
+		WithLocation(time.UTC),
+	)
+
+	expected := `
+        # HELP spotinst_ocean_aws_cluster_cost_previous_month Total cost of an ocean cluster (previous month)
+        # TYPE spotinst_ocean_aws_cluster_cost_previous_month gauge
+        spotinst_ocean_aws_cluster_cost_previous_month{ocean_id="foo",ocean_name="ocean-foo"} 200
+        # HELP spotinst_ocean_aws_namespace_cost_previous_month Total cost of a namespace (previous month)
+        # TYPE spotinst_ocean_aws_namespace_cost_previous_month gauge
+        spotinst_ocean_aws_namespace_cost_previous_month{namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 190
+        # HELP spotinst_ocean_aws_workload_cost_previous_month Total cost of a workload (previous month)
+        # TYPE spotinst_ocean_aws_workload_cost_previous_month gauge
+        spotinst_ocean_aws_workload_cost_previous_month{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 180
+    `
+
+	metricNames := []string{
+		"spotinst_ocean_aws_cluster_cost_previous_month",
+		"spotinst_ocean_aws_namespace_cost_previous_month",
+		"spotinst_ocean_aws_workload_cost_previous_month",
+	}
+
+	// The previous month is closed, its costs are only fetched once.
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), metricNames...))
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), metricNames...))
+	mockClient.AssertExpectations(t)
+}
+
+// taggedOceanCluster returns an ocean cluster with tags in the order of
+// keyValues.
+func taggedOceanCluster(id string, keyValues ...string) *aws.Cluster {
+	cluster := testhelpers.OceanClusters(id)[0]
+	cluster.Compute = &aws.Compute{LaunchSpecification: &aws.LaunchSpecification{}}
+
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		cluster.Compute.LaunchSpecification.Tags = append(cluster.Compute.LaunchSpecification.Tags, &aws.Tag{
+			Key:   spotinst.String(keyValues[i]),
+			Value: spotinst.String(keyValues[i+1]),
 		})
 	}
 
-	return clusters
+	return cluster
 }
 
 func clusterCostInput(clusterID string) *mcs.ClusterCostInput {
@@ -176,6 +756,36 @@ func clusterCostInput(clusterID string) *mcs.ClusterCostInput {
 	}
 }
 
+func fixedClock(value string) func() time.Time {
+	now, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+
+	return func() time.Time {
+		return now
+	}
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
+
+func clusterCostInputWindow(clusterID string, window CostWindow) *mcs.ClusterCostInput {
+	from, to := window.bounds(time.Now())
+
+	return &mcs.ClusterCostInput{
+		ClusterID: spotinst.String(clusterID),
+		FromDate:  spotinst.String(from.Format("2006-01-02")),
+		ToDate:    spotinst.String(to.Format("2006-01-02")),
+	}
+}
+
 func clusterCostOutput(cost float64, namespaceCosts ...*mcs.Namespace) *mcs.ClusterCostOutput {
 	return &mcs.ClusterCostOutput{
 		ClusterCosts: []*mcs.ClusterCost{
@@ -212,29 +822,3 @@ func resourceCostLabels(namespace, name string, cost float64, labels map[string]
 func resourceCost(namespace, name string, cost float64) *mcs.Resource {
 	return resourceCostLabels(namespace, name, cost, nil)
 }
-
-func TestAggregateHighCardinalityResources(t *testing.T) {
-	resources := []*mcs.Resource{
-		resourceCost("foo-ns", "foo", 1),
-		resourceCost("foo-ns", "foo-job-27745697", 1),
-		resourceCost("foo-ns", "baz-job-0e6b40aa-ffa2-4288-80ba-891fbad4b0ba", 20),
-		resourceCost("foo-ns", "foo-job-27745937", 2),
-		resourceCost("foo-ns", "baz-job-0e11d9c9-bcb9-4c71-b99e-afcecb5e5fc5", 10),
-		resourceCost("foo-ns", "bar", 3),
-		resourceCost("foo-ns", "0e11d9c9-bcb9-4c71-b99e-afcecb5e5fc5-qux-job", 5),
-		resourceCost("foo-ns", "0e6b40aa-ffa2-4288-80ba-891fbad4b0ba-qux-job", 7),
-		resourceCost("foo-ns", "27745697-bam-job", 3),
-		resourceCost("foo-ns", "27745937-bam-job", 4),
-	}
-
-	expected := []*mcs.Resource{
-		resourceCost("foo-ns", "foo", 1),
-		resourceCost("foo-ns", "foo-job", 3),
-		resourceCost("foo-ns", "bar", 3),
-		resourceCost("foo-ns", "baz-job", 30),
-		resourceCost("foo-ns", "qux-job", 12),
-		resourceCost("foo-ns", "bam-job", 7),
-	}
-
-	assert.ElementsMatch(t, expected, aggregateHighCardinalityResources(resources))
-}
@@ -0,0 +1,176 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+const clusterDailyCostsCollectorName = "ocean_aws_cluster_daily_costs"
+
+// OceanAWSClusterDailyCostsCollector is a prometheus collector for the daily
+// cost of Spotinst Ocean clusters on AWS.
+//
+// The costs of the full days within the lookback period are exposed with the
+// start of the respective day as timestamp. This allows to store the actual
+// daily spend instead of deriving it from month-to-date costs. Every day is
+// labeled with its distance to today ("days_ago"), so the timestamps of a
+// series advance by one day per day instead of going back in time.
+type OceanAWSClusterDailyCostsCollector struct {
+	costEmitter
+	*costFetcher
+
+	ctx          context.Context
+	logger       logr.Logger
+	clusters     ClusterLister
+	source       clusterSource[[]dailyCosts]
+	options      *options
+	descs        *costDescs
+	lookbackDays int
+}
+
+// dailyCosts holds the costs of a cluster for a single day.
+type dailyCosts struct {
+	day     time.Time
+	daysAgo int
+	costs   *mcs.ClusterCostOutput
+}
+
+// NewOceanAWSClusterDailyCostsCollector creates a new
+// OceanAWSClusterDailyCostsCollector for collecting the daily costs of the
+// Ocean clusters provided by clusters over the last lookbackDays full days.
+func NewOceanAWSClusterDailyCostsCollector(
+	ctx context.Context,
+	logger logr.Logger,
+	client OceanAWSClusterCostsClient,
+	clusters ClusterLister,
+	labelMappings labels.Mappings,
+	lookbackDays int,
+	opts ...Option,
+) *OceanAWSClusterDailyCostsCollector {
+	options := newOptions(opts...)
+
+	collector := &OceanAWSClusterDailyCostsCollector{
+		costEmitter: costEmitter{
+			labelMappings:         labelMappings,
+			labelPrecedence:       options.labelPrecedence,
+			normalization:         options.normalizationRules,
+			workloadsPerNamespace: options.workloadsPerNamespace,
+			workloadsPerCluster:   options.workloadsPerCluster,
+		},
+		costFetcher:  newCostFetcher(client, options.costCache),
+		ctx:          ctx,
+		logger:       logger,
+		clusters:     clusters,
+		options:      options,
+		descs:        newCostDescs("_daily", " per day", append(options.clusterLabelNames(), "days_ago"), labelMappings),
+		lookbackDays: lookbackDays,
+	}
+
+	fetch := fetchFunc[[]dailyCosts](collector.fetchDailyCosts).instrument(options.metrics, clusterDailyCostsCollectorName)
+	collector.source = newClusterSource(ctx, logger, clusters, options, fetch)
+
+	return collector
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *OceanAWSClusterDailyCostsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.descs.describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *OceanAWSClusterDailyCostsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(c.ctx, ch)
+}
+
+// CollectContext implements the ContextCollector interface.
+func (c *OceanAWSClusterDailyCostsCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	start := time.Now()
+
+	ctx, cancel := c.options.collectContext(c.ctx, ctx)
+	defer cancel()
+
+	clusters := c.clusters.Clusters()
+	results := getAll(ctx, c.source, clusters, c.options.concurrency)
+
+	for i, cluster := range clusters {
+		days, err := results[i].data, results[i].err
+		if err != nil {
+			logFetchError(c.logger, err, "failed to fetch daily cluster costs", cluster)
+			continue
+		}
+
+		for _, day := range days {
+			labelValues := append(c.options.clusterLabelValues(cluster), strconv.Itoa(day.daysAgo))
+
+			collectWithTimestamp(ch, day.day, func(ch chan<- prometheus.Metric) {
+				c.collectClusterCosts(ch, c.descs, day.costs.ClusterCosts, labelValues)
+			})
+		}
+	}
+
+	c.options.metrics.RetainClusters(clusterDailyCostsCollectorName, clusters)
+	c.options.metrics.ObserveScrapeDuration(clusterDailyCostsCollectorName, time.Since(start))
+}
+
+// fetchDailyCosts fetches the costs of cluster for each full day of the
+// lookback period, oldest first. Past days are closed, so their costs are
+// served from the cache if possible. Days that fail to fetch are logged and
+// omitted, an error is only returned if all days failed.
+func (c *OceanAWSClusterDailyCostsCollector) fetchDailyCosts(
+	ctx context.Context,
+	cluster *aws.Cluster,
+) ([]dailyCosts, error) {
+	now := c.options.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make([]dailyCosts, 0, c.lookbackDays)
+
+	var lastErr error
+
+	for i := c.lookbackDays; i > 0; i-- {
+		day := today.AddDate(0, 0, -i)
+
+		output, err := c.getClusterCosts(ctx, cluster, day, day.AddDate(0, 0, 1), true, now)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to fetch costs of %s: %w", day.Format("2006-01-02"), err)
+			c.logger.Error(lastErr, "failed to fetch daily cluster costs", "ocean_id", spotinst.StringValue(cluster.ID))
+
+			continue
+		}
+
+		days = append(days, dailyCosts{day: day, daysAgo: i, costs: output})
+	}
+
+	if len(days) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return days, nil
+}
+
+// collectWithTimestamp calls collect with a channel that attaches ts to all
+// metrics before sending them to ch.
+func collectWithTimestamp(ch chan<- prometheus.Metric, ts time.Time, collect func(chan<- prometheus.Metric)) {
+	timestamped := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for metric := range timestamped {
+			ch <- prometheus.NewMetricWithTimestamp(ts, metric)
+		}
+	}()
+
+	collect(timestamped)
+	close(timestamped)
+	<-done
+}
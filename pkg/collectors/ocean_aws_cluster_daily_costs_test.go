@@ -0,0 +1,252 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/go-logr/zapr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestOceanAWSClusterDailyCostsCollector(t *testing.T) {
+	testCases := []struct {
+		name     string
+		client   func() OceanAWSClusterCostsClient
+		expected string
+		options  []Option
+	}{
+		{
+			name: "daily costs with timestamps",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-08")).Return(clusterCostOutput(
+					20,
+					namespaceCost("foo-ns", 19, resourceCost("foo-ns", "foo-deployment", 18)),
+				), nil)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-09")).Return(clusterCostOutput(
+					30,
+					namespaceCost("foo-ns", 29, resourceCost("foo-ns", "foo-deployment", 28)),
+				), nil)
+				return mockClient
+			},
+			options: []Option{
+				WithClock(fixedClock("2024-03-10T12:00:00Z")),
+				WithLocation(time.UTC),
+			},
+			expected: `
+				# HELP spotinst_ocean_aws_cluster_cost_daily Total cost of an ocean cluster per day
+				# TYPE spotinst_ocean_aws_cluster_cost_daily gauge
+				spotinst_ocean_aws_cluster_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 30 1709942400000
+				spotinst_ocean_aws_cluster_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 20 1709856000000
+				# HELP spotinst_ocean_aws_namespace_cost_daily Total cost of a namespace per day
+				# TYPE spotinst_ocean_aws_namespace_cost_daily gauge
+				spotinst_ocean_aws_namespace_cost_daily{days_ago="1",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 29 1709942400000
+				spotinst_ocean_aws_namespace_cost_daily{days_ago="2",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 19 1709856000000
+				# HELP spotinst_ocean_aws_workload_cost_daily Total cost of a workload per day
+				# TYPE spotinst_ocean_aws_workload_cost_daily gauge
+				spotinst_ocean_aws_workload_cost_daily{days_ago="1",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 28 1709942400000
+				spotinst_ocean_aws_workload_cost_daily{days_ago="2",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 18 1709856000000
+				# HELP spotinst_ocean_aws_cluster_unallocated_cost_daily Cost of an ocean cluster not allocated to any namespace per day
+				# TYPE spotinst_ocean_aws_cluster_unallocated_cost_daily gauge
+				spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 1 1709942400000
+				spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 1 1709856000000
+				# HELP spotinst_ocean_aws_namespace_unallocated_cost_daily Cost of a namespace not allocated to any workload per day
+				# TYPE spotinst_ocean_aws_namespace_unallocated_cost_daily gauge
+				spotinst_ocean_aws_namespace_unallocated_cost_daily{days_ago="1",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 1 1709942400000
+				spotinst_ocean_aws_namespace_unallocated_cost_daily{days_ago="2",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 1 1709856000000
+			`,
+		},
+		{
+			name: "days start in configured time zone",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-09")).Return(clusterCostOutput(20), nil)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-10")).Return(clusterCostOutput(30), nil)
+				return mockClient
+			},
+			options: []Option{
+				// Already March 11th in Berlin.
+				WithClock(fixedClock("2024-03-10T23:30:00Z")),
+				WithLocation(mustLoadLocation("Europe/Berlin")),
+			},
+			expected: `
+				# HELP spotinst_ocean_aws_cluster_cost_daily Total cost of an ocean cluster per day
+				# TYPE spotinst_ocean_aws_cluster_cost_daily gauge
+				spotinst_ocean_aws_cluster_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 30 1710025200000
+				spotinst_ocean_aws_cluster_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 20 1709938800000
+				# HELP spotinst_ocean_aws_cluster_unallocated_cost_daily Cost of an ocean cluster not allocated to any namespace per day
+				# TYPE spotinst_ocean_aws_cluster_unallocated_cost_daily gauge
+				spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 30 1710025200000
+				spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 20 1709938800000
+			`,
+		},
+		{
+			name: "failing day is omitted",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-08")).Return(clusterCostOutput(20), nil)
+				mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-09")).Return(nil, errors.New("boom"))
+				return mockClient
+			},
+			options: []Option{
+				WithClock(fixedClock("2024-03-10T12:00:00Z")),
+				WithLocation(time.UTC),
+			},
+			expected: `
+				# HELP spotinst_ocean_aws_cluster_cost_daily Total cost of an ocean cluster per day
+				# TYPE spotinst_ocean_aws_cluster_cost_daily gauge
+				spotinst_ocean_aws_cluster_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 20 1709856000000
+				# HELP spotinst_ocean_aws_cluster_unallocated_cost_daily Cost of an ocean cluster not allocated to any namespace per day
+				# TYPE spotinst_ocean_aws_cluster_unallocated_cost_daily gauge
+				spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="2",ocean_id="foo",ocean_name="ocean-foo"} 20 1709856000000
+			`,
+		},
+		{
+			name: "all days failing omits cluster",
+			client: func() OceanAWSClusterCostsClient {
+				mockClient := new(mockOceanAWSClusterCostsClient)
+				mockClient.On("GetClusterCosts", mock.Anything, mock.Anything).Return(nil, errors.New("boom"))
+				return mockClient
+			},
+			options: []Option{
+				WithClock(fixedClock("2024-03-10T12:00:00Z")),
+				WithLocation(time.UTC),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			logger := zapr.NewLogger(zap.NewNop())
+			ctx := context.Background()
+			collector := NewOceanAWSClusterDailyCostsCollector(ctx, logger, testCase.client(), clusters.Static(testhelpers.OceanClusters("foo")), nil, 2, testCase.options...)
+
+			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(testCase.expected)))
+		})
+	}
+}
+
+func TestOceanAWSClusterDailyCostsCollectorCache(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(mockOceanAWSClusterCostsClient)
+	mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-09")).Return(clusterCostOutput(20), nil).Once()
+
+	collector := NewOceanAWSClusterDailyCostsCollector(
+		ctx, logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil, 1,
+		WithClock(fixedClock("2024-03-10T12:00:00Z")),
+		WithLocation(time.UTC),
+	)
+
+	expected := `
+		# HELP spotinst_ocean_aws_cluster_cost_daily Total cost of an ocean cluster per day
+		# TYPE spotinst_ocean_aws_cluster_cost_daily gauge
+		spotinst_ocean_aws_cluster_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 20 1709942400000
+		# HELP spotinst_ocean_aws_cluster_unallocated_cost_daily Cost of an ocean cluster not allocated to any namespace per day
+		# TYPE spotinst_ocean_aws_cluster_unallocated_cost_daily gauge
+		spotinst_ocean_aws_cluster_unallocated_cost_daily{days_ago="1",ocean_id="foo",ocean_name="ocean-foo"} 20 1709942400000
+	`
+
+	// Past days are final, their costs are only fetched once.
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	mockClient.AssertExpectations(t)
+}
+
+func TestOceanAWSClusterDailyCostsCollectorTimestamps(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(mockOceanAWSClusterCostsClient)
+	mockClient.On("GetClusterCosts", mock.Anything, mock.Anything).Return(clusterCostOutput(20), nil)
+
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	collector := NewOceanAWSClusterDailyCostsCollector(
+		ctx, logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil, 3,
+		WithClock(func() time.Time { return now }),
+		WithLocation(time.UTC),
+	)
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	latest := make(map[string]int64)
+
+	// Scrape every 6 hours for a few days. Each series must only ever move
+	// forward in time, otherwise samples would be rejected by Prometheus.
+	for i := 0; i < 16; i++ {
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+
+		series := 0
+
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				key := family.GetName()
+				for _, label := range metric.GetLabel() {
+					key += "," + label.GetName() + "=" + label.GetValue()
+				}
+
+				assert.GreaterOrEqual(t, metric.GetTimestampMs(), latest[key], "series %s moved backwards at %s", key, now)
+				latest[key] = metric.GetTimestampMs()
+				series++
+			}
+		}
+
+		// Every scrape exposes all days of the lookback period.
+		assert.Equal(t, 6, series)
+
+		now = now.Add(6 * time.Hour)
+	}
+}
+
+func TestOceanAWSClusterDailyCostsCollectorSharedCache(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(mockOceanAWSClusterCostsClient)
+	mockClient.On("GetClusterCosts", mock.Anything, dailyCostInput("foo", "2024-03-09")).Return(clusterCostOutput(20), nil).Once()
+
+	opts := []Option{
+		WithClock(fixedClock("2024-03-10T12:00:00Z")),
+		WithLocation(time.UTC),
+		WithCostCache(NewCostCache()),
+	}
+
+	costs := NewOceanAWSClusterCostsCollector(
+		ctx, logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil,
+		append(opts, WithCostWindows(CostWindowYesterday))...,
+	)
+	daily := NewOceanAWSClusterDailyCostsCollector(ctx, logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil, 1, opts...)
+
+	// The costs of yesterday are fetched once for both collectors.
+	assert.Equal(t, 2, testutil.CollectAndCount(costs))
+	assert.Equal(t, 2, testutil.CollectAndCount(daily))
+	mockClient.AssertExpectations(t)
+}
+
+func dailyCostInput(clusterID, day string) *mcs.ClusterCostInput {
+	from, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		panic(err)
+	}
+
+	return &mcs.ClusterCostInput{
+		ClusterID: spotinst.String(clusterID),
+		FromDate:  spotinst.String(day),
+		ToDate:    spotinst.String(from.AddDate(0, 0, 1).Format("2006-01-02")),
+	}
+}
@@ -3,13 +3,17 @@ package collectors
 import (
 	"context"
 	"strings"
+	"time"
 
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
 	"github.com/spotinst/spotinst-sdk-go/spotinst"
 )
 
+const resourceSuggestionsCollectorName = "ocean_aws_resource_suggestions"
+
 // OceanAWSResourceSuggestionsClient is the interface for something that can
 // list Ocean resource suggestions.
 //
@@ -27,7 +31,10 @@ type OceanAWSResourceSuggestionsCollector struct {
 	ctx                      context.Context
 	logger                   logr.Logger
 	client                   OceanAWSResourceSuggestionsClient
-	clusters                 []*aws.Cluster
+	clusters                 ClusterLister
+	labelMappings            labels.Mappings
+	source                   clusterSource[*aws.ListOceanResourceSuggestionsOutput]
+	options                  *options
 	requestedWorkloadCPU     *prometheus.Desc
 	suggestedWorkloadCPU     *prometheus.Desc
 	requestedWorkloadMemory  *prometheus.Desc
@@ -36,72 +43,115 @@ type OceanAWSResourceSuggestionsCollector struct {
 	suggestedContainerCPU    *prometheus.Desc
 	requestedContainerMemory *prometheus.Desc
 	suggestedContainerMemory *prometheus.Desc
+	// workloadSavings and namespaceSavings are only set if projected savings
+	// are enabled and a WorkloadIndex is configured.
+	workloadSavings  *prometheus.Desc
+	namespaceSavings *prometheus.Desc
 }
 
 // NewOceanAWSResourceSuggestionsCollector creates a new
 // OceanAWSResourceSuggestionsCollector for collecting the resource suggestions
-// for the provided list of Ocean clusters.
+// for the Ocean clusters provided by clusters.
+//
+// The workload labels mapped by labelMappings and the workload costs the
+// projected savings are based on are looked up in the WorkloadIndex passed via
+// WithWorkloadIndex, which is populated by an OceanAWSClusterCostsCollector.
+// Clusters are skipped until their workloads were indexed. Without an index,
+// the mapped labels are empty and no savings are projected even if enabled via
+// WithProjectedSavings.
 func NewOceanAWSResourceSuggestionsCollector(
 	ctx context.Context,
 	logger logr.Logger,
 	client OceanAWSResourceSuggestionsClient,
-	clusters []*aws.Cluster,
+	clusters ClusterLister,
+	labelMappings labels.Mappings,
+	opts ...Option,
 ) *OceanAWSResourceSuggestionsCollector {
+	options := newOptions(opts...)
+
+	workloadLabels := append(options.clusterLabelNames(), "workload", "namespace", "name")
+	workloadLabels = append(workloadLabels, labelMappings.LabelNames()...)
+	containerLabels := append(options.clusterLabelNames(), "workload", "namespace", "name", "container")
+	containerLabels = append(containerLabels, labelMappings.LabelNames()...)
+
 	collector := &OceanAWSResourceSuggestionsCollector{
-		ctx:      ctx,
-		logger:   logger,
-		client:   client,
-		clusters: clusters,
+		ctx:           ctx,
+		logger:        logger,
+		client:        client,
+		clusters:      clusters,
+		labelMappings: labelMappings,
+		options:       options,
 		requestedWorkloadCPU: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_cpu_requested"),
 			"The number of actual CPU units requested by a workload",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name"},
+			workloadLabels,
 			nil,
 		),
 		suggestedWorkloadCPU: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_cpu_suggested"),
 			"The number of CPU units suggested for a workload",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name"},
+			workloadLabels,
 			nil,
 		),
 		requestedWorkloadMemory: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_memory_requested"),
 			"The number of actual memory units requested by a workload",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name"},
+			workloadLabels,
 			nil,
 		),
 		suggestedWorkloadMemory: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_memory_suggested"),
 			"The number of memory units suggested for a workload",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name"},
+			workloadLabels,
 			nil,
 		),
 		requestedContainerCPU: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_container_cpu_requested"),
 			"The number of actual CPU units requested by a workload's container",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name", "container"},
+			containerLabels,
 			nil,
 		),
 		suggestedContainerCPU: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_container_cpu_suggested"),
 			"The number of CPU units suggested for a workload's container",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name", "container"},
+			containerLabels,
 			nil,
 		),
 		requestedContainerMemory: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_container_memory_requested"),
 			"The number of actual memory units requested by a workload's container",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name", "container"},
+			containerLabels,
 			nil,
 		),
 		suggestedContainerMemory: prometheus.NewDesc(
 			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_container_memory_suggested"),
 			"The number of memory units suggested for a workload's container",
-			[]string{"ocean_id", "ocean_name", "workload", "namespace", "name", "container"},
+			containerLabels,
 			nil,
 		),
 	}
 
+	if options.projectedSavings && options.workloadIndex != nil {
+		namespaceLabels := append(options.clusterLabelNames(), "namespace")
+		namespaceLabels = append(namespaceLabels, labelMappings.LabelNames()...)
+
+		collector.workloadSavings = prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "workload_projected_savings"),
+			"Projected monthly cost savings of a workload if its resource suggestions are applied",
+			workloadLabels,
+			nil,
+		)
+		collector.namespaceSavings = prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "ocean_aws", "namespace_projected_savings"),
+			"Projected monthly cost savings of a namespace if the resource suggestions of its workloads are applied",
+			namespaceLabels,
+			nil,
+		)
+	}
+
+	fetch := fetchFunc[*aws.ListOceanResourceSuggestionsOutput](collector.fetchResourceSuggestions).instrument(options.metrics, resourceSuggestionsCollectorName)
+	collector.source = newClusterSource(ctx, logger, clusters, options, fetch)
+
 	return collector
 }
 
@@ -115,24 +165,61 @@ func (c *OceanAWSResourceSuggestionsCollector) Describe(ch chan<- *prometheus.De
 	ch <- c.suggestedContainerCPU
 	ch <- c.requestedContainerMemory
 	ch <- c.suggestedContainerMemory
+
+	if c.workloadSavings != nil {
+		ch <- c.workloadSavings
+		ch <- c.namespaceSavings
+	}
 }
 
 // Collect implements the prometheus.Collector interface.
 func (c *OceanAWSResourceSuggestionsCollector) Collect(ch chan<- prometheus.Metric) {
-	for _, cluster := range c.clusters {
-		input := &aws.ListOceanResourceSuggestionsInput{
-			OceanID: cluster.ID,
-		}
+	c.CollectContext(c.ctx, ch)
+}
+
+// CollectContext implements the ContextCollector interface.
+func (c *OceanAWSResourceSuggestionsCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	start := time.Now()
 
-		output, err := c.client.ListOceanResourceSuggestions(c.ctx, input)
+	ctx, cancel := c.options.collectContext(c.ctx, ctx)
+	defer cancel()
+
+	clusters := c.clusters.Clusters()
+	results := getAll(ctx, c.source, clusters, c.options.concurrency)
+
+	// Metrics are emitted sequentially in the order of clusters after all
+	// data was fetched, to keep the output deterministic.
+	for i, cluster := range clusters {
+		output, err := results[i].data, results[i].err
 		if err != nil {
-			clusterID := spotinst.StringValue(cluster.ID)
-			c.logger.Error(err, "failed to list resource suggestions", "ocean_id", clusterID)
+			logFetchError(c.logger, err, "failed to list resource suggestions", cluster)
+			continue
+		}
+
+		// The labels and costs of the workloads are only known once the
+		// cluster was indexed. Exporting the suggestions before would first
+		// create series with empty labels and replace them right after.
+		if !c.options.workloadIndex.ready(cluster) {
+			c.logger.V(1).Info("skipping cluster without indexed workloads", "ocean_id", spotinst.StringValue(cluster.ID))
 			continue
 		}
 
 		c.collectWorkloadSuggestions(ch, output.Suggestions, cluster)
 	}
+
+	c.options.metrics.RetainClusters(resourceSuggestionsCollectorName, clusters)
+	c.options.metrics.ObserveScrapeDuration(resourceSuggestionsCollectorName, time.Since(start))
+}
+
+func (c *OceanAWSResourceSuggestionsCollector) fetchResourceSuggestions(
+	ctx context.Context,
+	cluster *aws.Cluster,
+) (*aws.ListOceanResourceSuggestionsOutput, error) {
+	input := &aws.ListOceanResourceSuggestionsInput{
+		OceanID: cluster.ID,
+	}
+
+	return c.client.ListOceanResourceSuggestions(ctx, input)
 }
 
 func (c *OceanAWSResourceSuggestionsCollector) collectWorkloadSuggestions(
@@ -140,21 +227,41 @@ func (c *OceanAWSResourceSuggestionsCollector) collectWorkloadSuggestions(
 	suggestions []*aws.ResourceSuggestion,
 	cluster *aws.Cluster,
 ) {
+	clusterLabelValues := c.options.clusterLabelValues(cluster)
+
+	var namespaceSavings seriesSet
+
 	for _, suggestion := range suggestions {
-		labelValues := []string{
-			spotinst.StringValue(cluster.ID),
-			spotinst.StringValue(cluster.Name),
-			strings.ToLower(spotinst.StringValue(suggestion.ResourceType)),
-			spotinst.StringValue(suggestion.Namespace),
-			spotinst.StringValue(suggestion.ResourceName),
-		}
+		kind := spotinst.StringValue(suggestion.ResourceType)
+		namespace := spotinst.StringValue(suggestion.Namespace)
+		name := spotinst.StringValue(suggestion.ResourceName)
+
+		workload, namespaceLabels, found := c.options.workloadIndex.get(cluster, namespace, kind, name)
+		mappedLabelValues := c.labelMappings.LabelValues(c.options.labelPrecedence.labelMaps(workload.labels, namespaceLabels)...)
+
+		baseLabelValues := append(clusterLabelValues[:len(clusterLabelValues):len(clusterLabelValues)], strings.ToLower(kind), namespace, name)
+		labelValues := append(baseLabelValues[:len(baseLabelValues):len(baseLabelValues)], mappedLabelValues...)
 
 		collectGaugeValue(ch, c.requestedWorkloadCPU, spotinst.Float64Value(suggestion.RequestedCPU), labelValues)
 		collectGaugeValue(ch, c.suggestedWorkloadCPU, spotinst.Float64Value(suggestion.SuggestedCPU), labelValues)
 		collectGaugeValue(ch, c.requestedWorkloadMemory, spotinst.Float64Value(suggestion.RequestedMemory), labelValues)
 		collectGaugeValue(ch, c.suggestedWorkloadMemory, spotinst.Float64Value(suggestion.SuggestedMemory), labelValues)
 
-		c.collectContainerSuggestions(ch, suggestion.Containers, labelValues)
+		c.collectContainerSuggestions(ch, suggestion.Containers, baseLabelValues, mappedLabelValues)
+
+		if c.workloadSavings != nil && found {
+			savings := projectedSavings(workload.monthlyCost, c.options.savingsCPUWeight, suggestion)
+
+			namespaceLabelValues := append(clusterLabelValues[:len(clusterLabelValues):len(clusterLabelValues)], namespace)
+			namespaceLabelValues = append(namespaceLabelValues, c.labelMappings.LabelValues(namespaceLabels)...)
+
+			collectGaugeValue(ch, c.workloadSavings, savings, labelValues)
+			namespaceSavings.add(namespaceLabelValues, savings)
+		}
+	}
+
+	for _, namespace := range namespaceSavings.series {
+		collectGaugeValue(ch, c.namespaceSavings, namespace.value, namespace.labelValues)
 	}
 }
 
@@ -162,9 +269,11 @@ func (c *OceanAWSResourceSuggestionsCollector) collectContainerSuggestions(
 	ch chan<- prometheus.Metric,
 	suggestions []*aws.ContainerResourceSuggestion,
 	workloadLabelValues []string,
+	mappedLabelValues []string,
 ) {
 	for _, suggestion := range suggestions {
-		labelValues := append(workloadLabelValues, spotinst.StringValue(suggestion.Name))
+		labelValues := append(workloadLabelValues[:len(workloadLabelValues):len(workloadLabelValues)], spotinst.StringValue(suggestion.Name))
+		labelValues = append(labelValues, mappedLabelValues...)
 
 		collectGaugeValue(ch, c.requestedContainerCPU, spotinst.Float64Value(suggestion.RequestedCPU), labelValues)
 		collectGaugeValue(ch, c.suggestedContainerCPU, spotinst.Float64Value(suggestion.SuggestedCPU), labelValues)
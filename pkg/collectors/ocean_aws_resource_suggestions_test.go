@@ -5,9 +5,14 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
 	"github.com/go-logr/zapr"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
 	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
 	"github.com/spotinst/spotinst-sdk-go/spotinst"
 	"github.com/stretchr/testify/assert"
@@ -35,10 +40,13 @@ func (m *mockOceanAWSResourceSuggestionsClient) ListOceanResourceSuggestions(
 
 func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 	testCases := []struct {
-		name     string
-		client   func() OceanAWSResourceSuggestionsClient
-		expected string
-		clusters []*aws.Cluster
+		name          string
+		client        func() OceanAWSResourceSuggestionsClient
+		expected      string
+		clusters      []*aws.Cluster
+		labelMappings labels.Mappings
+		options       []Option
+		metricNames   []string
 	}{
 		{
 			name: "no cluster, no output",
@@ -55,7 +63,7 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(nil, errors.New("nonexistent"))
 				return mockClient
 			},
-			clusters: oceanClusters("nonexistent"),
+			clusters: testhelpers.OceanClusters("nonexistent"),
 		},
 		{
 			name: "one cluster",
@@ -71,7 +79,7 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
 				return mockClient
 			},
-			clusters: oceanClusters("foo"),
+			clusters: testhelpers.OceanClusters("foo"),
 			expected: `
                 # HELP spotinst_ocean_aws_workload_container_cpu_requested The number of actual CPU units requested by a workload's container
                 # TYPE spotinst_ocean_aws_workload_container_cpu_requested gauge
@@ -97,6 +105,83 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
                 # HELP spotinst_ocean_aws_workload_memory_suggested The number of memory units suggested for a workload
                 # TYPE spotinst_ocean_aws_workload_memory_suggested gauge
                 spotinst_ocean_aws_workload_memory_suggested{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 100
+            `,
+		},
+		{
+			name: "cluster tag labels",
+			client: func() OceanAWSResourceSuggestionsClient {
+				input := resourceSuggestionsInput("foo")
+				output := resourceSuggestionsOutput(resourceSuggestion(
+					"foo-deployment", "deployment", "foo-ns",
+					200, 1000, 100, 2000,
+					containerResourceSuggestion("foo-container", 200, 900, 90, 1800),
+				))
+
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
+				return mockClient
+			},
+			clusters: []*aws.Cluster{taggedOceanCluster("foo", "team", "foo-team")},
+			options: []Option{WithClusterTagMappings(func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team=cluster_team")
+				return mappings
+			}())},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_container_cpu_requested The number of actual CPU units requested by a workload's container
+                # TYPE spotinst_ocean_aws_workload_container_cpu_requested gauge
+                spotinst_ocean_aws_workload_container_cpu_requested{cluster_team="foo-team",container="foo-container",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 900
+                # HELP spotinst_ocean_aws_workload_container_cpu_suggested The number of CPU units suggested for a workload's container
+                # TYPE spotinst_ocean_aws_workload_container_cpu_suggested gauge
+                spotinst_ocean_aws_workload_container_cpu_suggested{cluster_team="foo-team",container="foo-container",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 200
+                # HELP spotinst_ocean_aws_workload_container_memory_requested The number of actual memory units requested by a workload's container
+                # TYPE spotinst_ocean_aws_workload_container_memory_requested gauge
+                spotinst_ocean_aws_workload_container_memory_requested{cluster_team="foo-team",container="foo-container",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 1800
+                # HELP spotinst_ocean_aws_workload_container_memory_suggested The number of memory units suggested for a workload's container
+                # TYPE spotinst_ocean_aws_workload_container_memory_suggested gauge
+                spotinst_ocean_aws_workload_container_memory_suggested{cluster_team="foo-team",container="foo-container",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 90
+                # HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_requested gauge
+                spotinst_ocean_aws_workload_cpu_requested{cluster_team="foo-team",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 1000
+                # HELP spotinst_ocean_aws_workload_cpu_suggested The number of CPU units suggested for a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_suggested gauge
+                spotinst_ocean_aws_workload_cpu_suggested{cluster_team="foo-team",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 200
+                # HELP spotinst_ocean_aws_workload_memory_requested The number of actual memory units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_memory_requested gauge
+                spotinst_ocean_aws_workload_memory_requested{cluster_team="foo-team",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 2000
+                # HELP spotinst_ocean_aws_workload_memory_suggested The number of memory units suggested for a workload
+                # TYPE spotinst_ocean_aws_workload_memory_suggested gauge
+                spotinst_ocean_aws_workload_memory_suggested{cluster_team="foo-team",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 100
+            `,
+		},
+		{
+			name: "static cluster labels",
+			client: func() OceanAWSResourceSuggestionsClient {
+				input := resourceSuggestionsInput("foo")
+				output := resourceSuggestionsOutput(resourceSuggestion("foo-deployment", "deployment", "foo-ns", 200, 1000, 100, 2000))
+
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{WithClusterStaticLabels(func() clusters.StaticLabels {
+				var staticLabels clusters.StaticLabels
+				_ = staticLabels.Set("foo:env=prod")
+				return staticLabels
+			}())},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_requested gauge
+                spotinst_ocean_aws_workload_cpu_requested{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 1000
+                # HELP spotinst_ocean_aws_workload_cpu_suggested The number of CPU units suggested for a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_suggested gauge
+                spotinst_ocean_aws_workload_cpu_suggested{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 200
+                # HELP spotinst_ocean_aws_workload_memory_requested The number of actual memory units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_memory_requested gauge
+                spotinst_ocean_aws_workload_memory_requested{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 2000
+                # HELP spotinst_ocean_aws_workload_memory_suggested The number of memory units suggested for a workload
+                # TYPE spotinst_ocean_aws_workload_memory_suggested gauge
+                spotinst_ocean_aws_workload_memory_suggested{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 100
             `,
 		},
 		{
@@ -121,7 +206,7 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
 				return mockClient
 			},
-			clusters: oceanClusters("foo"),
+			clusters: testhelpers.OceanClusters("foo"),
 			expected: `
                 # HELP spotinst_ocean_aws_workload_container_cpu_requested The number of actual CPU units requested by a workload's container
                 # TYPE spotinst_ocean_aws_workload_container_cpu_requested gauge
@@ -157,6 +242,199 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
                 spotinst_ocean_aws_workload_memory_suggested{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 100
             `,
 		},
+		{
+			name: "workload labels",
+			client: func() OceanAWSResourceSuggestionsClient {
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+
+				input := resourceSuggestionsInput("foo")
+				output := resourceSuggestionsOutput(
+					resourceSuggestion(
+						"foo-deployment", "deployment", "foo-ns",
+						200, 1000, 100, 2000,
+						containerResourceSuggestion("foo-container", 200, 900, 90, 1800),
+					),
+					resourceSuggestion(
+						"bar-daemonset", "daemonSet", "foo-ns",
+						199, 999, 99, 1999,
+					),
+					resourceSuggestion(
+						"baz-deployment", "deployment", "baz-ns",
+						198, 998, 98, 1998,
+					),
+				)
+
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team,env")
+				return mappings
+			}(),
+			options: []Option{
+				WithWorkloadIndex(func() *WorkloadIndex {
+					namespace := namespaceCostLabels(
+						"foo-ns", 100,
+						map[string]string{"env": "prod"},
+						resourceCostLabels("foo-ns", "foo-deployment", 60, map[string]string{"team": "foo-team"}),
+					)
+					namespace.DaemonSets = []*mcs.Resource{
+						resourceCostLabels("foo-ns", "bar-daemonset", 40, map[string]string{"team": "bar-team", "env": "dev"}),
+					}
+
+					index := NewWorkloadIndex()
+					index.update(testhelpers.OceanClusters("foo")[0], clusterCostOutput(100, namespace), time.Now())
+					return index
+				}()),
+				WithLabelPrecedence(LabelPrecedence{LabelSourceWorkload, LabelSourceNamespace}),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_container_cpu_requested The number of actual CPU units requested by a workload's container
+                # TYPE spotinst_ocean_aws_workload_container_cpu_requested gauge
+                spotinst_ocean_aws_workload_container_cpu_requested{container="foo-container",env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 900
+                # HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_requested gauge
+                spotinst_ocean_aws_workload_cpu_requested{env="",name="baz-deployment",namespace="baz-ns",ocean_id="foo",ocean_name="ocean-foo",team="",workload="deployment"} 998
+                spotinst_ocean_aws_workload_cpu_requested{env="dev",name="bar-daemonset",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="bar-team",workload="daemonset"} 999
+                spotinst_ocean_aws_workload_cpu_requested{env="prod",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 1000
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_workload_container_cpu_requested",
+				"spotinst_ocean_aws_workload_cpu_requested",
+			},
+		},
+		{
+			name: "cluster missing from workload index is skipped",
+			client: func() OceanAWSResourceSuggestionsClient {
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, resourceSuggestionsInput("foo")).Return(resourceSuggestionsOutput(
+					resourceSuggestion("foo-deployment", "deployment", "foo-ns", 200, 1000, 100, 2000),
+				), nil)
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, resourceSuggestionsInput("bar")).Return(resourceSuggestionsOutput(
+					resourceSuggestion("bar-deployment", "deployment", "bar-ns", 199, 999, 99, 1999),
+				), nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo", "bar"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("team")
+				return mappings
+			}(),
+			options: []Option{
+				WithWorkloadIndex(func() *WorkloadIndex {
+					index := NewWorkloadIndex()
+					index.update(testhelpers.OceanClusters("foo")[0], clusterCostOutput(
+						50,
+						namespaceCost("foo-ns", 50, resourceCostLabels("foo-ns", "foo-deployment", 50, map[string]string{"team": "foo-team"})),
+					), time.Now())
+					return index
+				}()),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
+                # TYPE spotinst_ocean_aws_workload_cpu_requested gauge
+                spotinst_ocean_aws_workload_cpu_requested{name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 1000
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_workload_cpu_requested",
+			},
+		},
+		{
+			name: "projected savings",
+			client: func() OceanAWSResourceSuggestionsClient {
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+
+				input := resourceSuggestionsInput("foo")
+				output := resourceSuggestionsOutput(
+					resourceSuggestion(
+						"foo-deployment", "deployment", "foo-ns",
+						500, 1000, 1500, 2000,
+					),
+					resourceSuggestion(
+						"bar-deployment", "deployment", "foo-ns",
+						1250, 1000, 1000, 1000,
+					),
+					resourceSuggestion(
+						"baz-deployment", "deployment", "foo-ns",
+						100, 1000, 100, 1000,
+					),
+				)
+
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			labelMappings: func() labels.Mappings {
+				mappings, _ := labels.ParseMappings("env")
+				return mappings
+			}(),
+			options: []Option{
+				WithWorkloadIndex(func() *WorkloadIndex {
+					// Half of March has elapsed, so the monthly costs are
+					// twice the month-to-date costs.
+					index := NewWorkloadIndex()
+					index.update(testhelpers.OceanClusters("foo")[0], clusterCostOutput(
+						75,
+						namespaceCostLabels(
+							"foo-ns", 75,
+							map[string]string{"env": "prod"},
+							resourceCost("foo-ns", "foo-deployment", 50),
+							resourceCost("foo-ns", "bar-deployment", 25),
+						),
+					), fixedClock("2024-03-16T12:00:00Z")())
+					return index
+				}()),
+				WithProjectedSavings(true, 0.75),
+			},
+			expected: `
+                # HELP spotinst_ocean_aws_namespace_projected_savings Projected monthly cost savings of a namespace if the resource suggestions of its workloads are applied
+                # TYPE spotinst_ocean_aws_namespace_projected_savings gauge
+                spotinst_ocean_aws_namespace_projected_savings{env="prod",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo"} 34.375
+                # HELP spotinst_ocean_aws_workload_projected_savings Projected monthly cost savings of a workload if its resource suggestions are applied
+                # TYPE spotinst_ocean_aws_workload_projected_savings gauge
+                spotinst_ocean_aws_workload_projected_savings{env="",name="bar-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} -9.375
+                spotinst_ocean_aws_workload_projected_savings{env="",name="foo-deployment",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",workload="deployment"} 43.75
+            `,
+			metricNames: []string{
+				"spotinst_ocean_aws_namespace_projected_savings",
+				"spotinst_ocean_aws_workload_projected_savings",
+			},
+		},
+		{
+			name: "projected savings disabled",
+			client: func() OceanAWSResourceSuggestionsClient {
+				mockClient := new(mockOceanAWSResourceSuggestionsClient)
+
+				input := resourceSuggestionsInput("foo")
+				output := resourceSuggestionsOutput(
+					resourceSuggestion(
+						"foo-deployment", "deployment", "foo-ns",
+						500, 1000, 1500, 2000,
+					),
+				)
+
+				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
+				return mockClient
+			},
+			clusters: testhelpers.OceanClusters("foo"),
+			options: []Option{
+				WithWorkloadIndex(func() *WorkloadIndex {
+					index := NewWorkloadIndex()
+					index.update(testhelpers.OceanClusters("foo")[0], clusterCostOutput(
+						50,
+						namespaceCost("foo-ns", 50, resourceCost("foo-ns", "foo-deployment", 50)),
+					), time.Now())
+					return index
+				}()),
+			},
+			expected: ``,
+			metricNames: []string{
+				"spotinst_ocean_aws_namespace_projected_savings",
+				"spotinst_ocean_aws_workload_projected_savings",
+			},
+		},
 		{
 			name: "three clusters, one nonexistent",
 			client: func() OceanAWSResourceSuggestionsClient {
@@ -182,7 +460,7 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 				mockClient.On("ListOceanResourceSuggestions", mock.Anything, input).Return(output, nil)
 				return mockClient
 			},
-			clusters: oceanClusters("foo", "nonexistent", "bar"),
+			clusters: testhelpers.OceanClusters("foo", "nonexistent", "bar"),
 			expected: `
                 # HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
                 # TYPE spotinst_ocean_aws_workload_cpu_requested gauge
@@ -209,9 +487,9 @@ func TestOceanAWSResourceSuggestionsCollector(t *testing.T) {
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
 			ctx := context.Background()
-			collector := NewOceanAWSResourceSuggestionsCollector(ctx, logger, testCase.client(), testCase.clusters)
+			collector := NewOceanAWSResourceSuggestionsCollector(ctx, logger, testCase.client(), clusters.Static(testCase.clusters), testCase.labelMappings, testCase.options...)
 
-			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(testCase.expected)))
+			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(testCase.expected), testCase.metricNames...))
 		})
 	}
 }
@@ -0,0 +1,311 @@
+package collectors
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/apierrors"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/selfmetrics"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+)
+
+// RetryPolicy configures how failed Spotinst API calls are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per API call, including
+	// the first one. Values below 1 are treated as 1.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the randomized delay before the
+	// first retry. It is doubled for every subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the upper bound of the randomized delay.
+	MaxBackoff time.Duration
+}
+
+// backoff returns the jittered delay before the given retry (starting at 0).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.InitialBackoff << retry
+	if limit <= 0 || (p.MaxBackoff > 0 && limit > p.MaxBackoff) {
+		limit = p.MaxBackoff
+	}
+
+	if limit <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+// RateLimiter limits the rate of Spotinst API calls. It is safe for
+// concurrent use and is meant to be shared between clients to enforce a global
+// limit. A nil *RateLimiter does not limit at all.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	// released holds the slots before next that were reserved by calls to
+	// Wait whose context was done before the slot was reached.
+	released []time.Time
+}
+
+// NewRateLimiter creates a new *RateLimiter which allows up to
+// requestsPerSecond calls. Returns nil if requestsPerSecond is not positive.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+	}
+}
+
+// Wait blocks until the next call is allowed or ctx is done. It returns an
+// error without waiting if ctx expires before the next call would be allowed.
+// If ctx is done while waiting, the reserved slot is released for other
+// calls.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	slot, err := l.reserve(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if err := sleep(ctx, slot.Sub(now)); err != nil {
+		l.release(slot)
+		return err
+	}
+
+	return nil
+}
+
+// reserve reserves the earliest free slot at or after now.
+func (l *RateLimiter) reserve(ctx context.Context, now time.Time) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Released slots in the past can't be used anymore.
+	released := l.released[:0]
+	for _, slot := range l.released {
+		if !slot.Before(now) {
+			released = append(released, slot)
+		}
+	}
+	l.released = released
+
+	index := -1
+
+	for i, slot := range l.released {
+		if index < 0 || slot.Before(l.released[index]) {
+			index = i
+		}
+	}
+
+	slot := l.next
+	if index >= 0 {
+		slot = l.released[index]
+	} else if slot.Before(now) {
+		slot = now
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && slot.After(deadline) {
+		return slot, context.DeadlineExceeded
+	}
+
+	if index >= 0 {
+		l.released = append(l.released[:index], l.released[index+1:]...)
+	} else {
+		l.next = slot.Add(l.interval)
+	}
+
+	return slot, nil
+}
+
+// release returns slot to the limiter. If it is the last reserved slot, next
+// is moved back instead, together with any released slots directly before it.
+func (l *RateLimiter) release(slot time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.released = append(l.released, slot)
+
+	for {
+		last := l.next.Add(-l.interval)
+		found := false
+
+		for i, released := range l.released {
+			if released.Equal(last) {
+				l.released = append(l.released[:i], l.released[i+1:]...)
+				l.next = last
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return
+		}
+	}
+}
+
+// retrier retries calls that failed with retryable errors.
+type retrier struct {
+	policy  RetryPolicy
+	limiter *RateLimiter
+	metrics *selfmetrics.Metrics
+}
+
+// do calls fn until it succeeds, fails with a non-retryable error or the
+// retry policy is exhausted. It gives up early if the next attempt would
+// happen after the deadline of ctx. Every failed attempt is counted as an
+// error of endpoint.
+func (r *retrier) do(ctx context.Context, endpoint string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := r.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err != nil {
+			r.metrics.ObserveAPIError(endpoint, err)
+		}
+
+		if err == nil || !apierrors.IsTransient(err) || attempt >= r.policy.MaxAttempts {
+			return err
+		}
+
+		delay := r.policy.backoff(attempt - 1)
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type retryingOceanAWSClusterCostsClient struct {
+	retrier
+	client OceanAWSClusterCostsClient
+}
+
+// NewRetryingOceanAWSClusterCostsClient wraps client to retry retryable errors
+// according to policy. Calls are rate limited by limiter, which may be nil.
+// Every failed attempt is counted in metrics, which may be nil as well.
+func NewRetryingOceanAWSClusterCostsClient(
+	client OceanAWSClusterCostsClient,
+	policy RetryPolicy,
+	limiter *RateLimiter,
+	metrics *selfmetrics.Metrics,
+) OceanAWSClusterCostsClient {
+	return &retryingOceanAWSClusterCostsClient{
+		retrier: retrier{policy: policy, limiter: limiter, metrics: metrics},
+		client:  client,
+	}
+}
+
+// GetClusterCosts implements OceanAWSClusterCostsClient.
+func (c *retryingOceanAWSClusterCostsClient) GetClusterCosts(
+	ctx context.Context,
+	input *mcs.ClusterCostInput,
+) (output *mcs.ClusterCostOutput, err error) {
+	err = c.do(ctx, "GetClusterCosts", func() error {
+		output, err = c.client.GetClusterCosts(ctx, input)
+		return err
+	})
+
+	return output, err
+}
+
+type retryingOceanAWSResourceSuggestionsClient struct {
+	retrier
+	client OceanAWSResourceSuggestionsClient
+}
+
+// NewRetryingOceanAWSResourceSuggestionsClient wraps client to retry retryable
+// errors according to policy. Calls are rate limited by limiter, which may be
+// nil. Every failed attempt is counted in metrics, which may be nil as well.
+func NewRetryingOceanAWSResourceSuggestionsClient(
+	client OceanAWSResourceSuggestionsClient,
+	policy RetryPolicy,
+	limiter *RateLimiter,
+	metrics *selfmetrics.Metrics,
+) OceanAWSResourceSuggestionsClient {
+	return &retryingOceanAWSResourceSuggestionsClient{
+		retrier: retrier{policy: policy, limiter: limiter, metrics: metrics},
+		client:  client,
+	}
+}
+
+// ListOceanResourceSuggestions implements OceanAWSResourceSuggestionsClient.
+func (c *retryingOceanAWSResourceSuggestionsClient) ListOceanResourceSuggestions(
+	ctx context.Context,
+	input *aws.ListOceanResourceSuggestionsInput,
+) (output *aws.ListOceanResourceSuggestionsOutput, err error) {
+	err = c.do(ctx, "ListOceanResourceSuggestions", func() error {
+		output, err = c.client.ListOceanResourceSuggestions(ctx, input)
+		return err
+	})
+
+	return output, err
+}
+
+type retryingOceanAWSClustersClient struct {
+	retrier
+	client clusters.OceanAWSClustersClient
+}
+
+// NewRetryingOceanAWSClustersClient wraps client to retry retryable errors
+// according to policy. Calls are rate limited by limiter, which may be nil.
+// Every failed attempt is counted in metrics, which may be nil as well.
+func NewRetryingOceanAWSClustersClient(
+	client clusters.OceanAWSClustersClient,
+	policy RetryPolicy,
+	limiter *RateLimiter,
+	metrics *selfmetrics.Metrics,
+) clusters.OceanAWSClustersClient {
+	return &retryingOceanAWSClustersClient{
+		retrier: retrier{policy: policy, limiter: limiter, metrics: metrics},
+		client:  client,
+	}
+}
+
+// ListClusters implements clusters.OceanAWSClustersClient.
+func (c *retryingOceanAWSClustersClient) ListClusters(
+	ctx context.Context,
+	input *aws.ListClustersInput,
+) (output *aws.ListClustersOutput, err error) {
+	err = c.do(ctx, "ListClusters", func() error {
+		output, err = c.client.ListClusters(ctx, input)
+		return err
+	})
+
+	return output, err
+}
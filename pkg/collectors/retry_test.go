@@ -0,0 +1,174 @@
+package collectors
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/selfmetrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRetryingOceanAWSClusterCostsClient(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+
+	input := clusterCostInput("foo")
+	output := clusterCostOutput(200)
+
+	t.Run("retries retryable errors", func(t *testing.T) {
+		mockClient := new(mockOceanAWSClusterCostsClient)
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusTooManyRequests)).Once()
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusBadGateway)).Once()
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(output, nil).Once()
+
+		metrics := selfmetrics.New()
+		retryingClient := NewRetryingOceanAWSClusterCostsClient(mockClient, policy, nil, metrics)
+
+		result, err := retryingClient.GetClusterCosts(context.Background(), input)
+		assert.NoError(t, err)
+		assert.Equal(t, output, result)
+		mockClient.AssertExpectations(t)
+
+		// Errors are counted per attempt, even if a retry succeeds.
+		expected := `
+            # HELP spotinst_exporter_api_errors_total Total number of failed Spotinst API calls
+            # TYPE spotinst_exporter_api_errors_total counter
+            spotinst_exporter_api_errors_total{class="rate_limited",endpoint="GetClusterCosts"} 1
+            spotinst_exporter_api_errors_total{class="server_error",endpoint="GetClusterCosts"} 1
+        `
+
+		assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(expected), "spotinst_exporter_api_errors_total"))
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		mockClient := new(mockOceanAWSClusterCostsClient)
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusServiceUnavailable)).Times(3)
+
+		retryingClient := NewRetryingOceanAWSClusterCostsClient(mockClient, policy, nil, nil)
+
+		_, err := retryingClient.GetClusterCosts(context.Background(), input)
+		assert.Error(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("does not retry non-retryable errors", func(t *testing.T) {
+		mockClient := new(mockOceanAWSClusterCostsClient)
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusBadRequest)).Once()
+
+		retryingClient := NewRetryingOceanAWSClusterCostsClient(mockClient, policy, nil, nil)
+
+		_, err := retryingClient.GetClusterCosts(context.Background(), input)
+		assert.Error(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("gives up before the deadline", func(t *testing.T) {
+		mockClient := new(mockOceanAWSClusterCostsClient)
+		mockClient.On("GetClusterCosts", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusInternalServerError)).Once()
+
+		slowPolicy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+		retryingClient := NewRetryingOceanAWSClusterCostsClient(mockClient, slowPolicy, nil, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := retryingClient.GetClusterCosts(ctx, input)
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestRetryingOceanAWSClustersClient(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	input := &aws.ListClustersInput{}
+	output := &aws.ListClustersOutput{Clusters: testhelpers.OceanClusters("foo")}
+
+	mockClient := new(testhelpers.MockOceanAWSClustersClient)
+	mockClient.On("ListClusters", mock.Anything, input).Return(nil, testhelpers.APIError(http.StatusServiceUnavailable)).Once()
+	mockClient.On("ListClusters", mock.Anything, input).Return(output, nil).Once()
+
+	metrics := selfmetrics.New()
+	retryingClient := NewRetryingOceanAWSClustersClient(mockClient, policy, nil, metrics)
+
+	result, err := retryingClient.ListClusters(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, output, result)
+	mockClient.AssertExpectations(t)
+
+	expected := `
+        # HELP spotinst_exporter_api_errors_total Total number of failed Spotinst API calls
+        # TYPE spotinst_exporter_api_errors_total counter
+        spotinst_exporter_api_errors_total{class="server_error",endpoint="ListClusters"} 1
+    `
+
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(expected), "spotinst_exporter_api_errors_total"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Nil(t, NewRateLimiter(0))
+	assert.NoError(t, (*RateLimiter)(nil).Wait(ctx))
+
+	limiter := NewRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, limiter.Wait(ctx))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+
+	limiter = NewRateLimiter(1)
+	assert.NoError(t, limiter.Wait(ctx))
+	assert.ErrorIs(t, limiter.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestRateLimiterReleasesCancelledSlots(t *testing.T) {
+	limiter := NewRateLimiter(10)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	// The slot reserved by a cancelled call is released...
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx), context.DeadlineExceeded)
+
+	// ...so the next call gets it instead of the one after it.
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, limiter.Wait(ctx))
+
+	// Slots released while later slots are reserved are reused as well.
+	limiter = NewRateLimiter(10)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	cancelled, cancelWait := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+
+	go func() { errs <- limiter.Wait(cancelled) }()
+	time.Sleep(10 * time.Millisecond)
+	go func() { errs <- limiter.Wait(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelWait()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, limiter.Wait(ctx))
+	assert.NoError(t, <-errs)
+}
@@ -0,0 +1,33 @@
+package collectors
+
+import (
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// DefaultSavingsCPUWeight is the default share of a workload's cost
+// attributed to its CPU requests when projecting savings. The remaining share
+// is attributed to its memory requests.
+const DefaultSavingsCPUWeight = 0.5
+
+// projectedSavings returns the savings of a workload with the given monthly
+// cost if its resource suggestion is applied. The cost is split into a CPU and
+// a memory share according to cpuWeight, each of which is reduced by the
+// ratio of suggested to requested resources. Negative savings mean that more
+// resources are suggested than requested.
+func projectedSavings(monthlyCost, cpuWeight float64, suggestion *aws.ResourceSuggestion) float64 {
+	cpuSavings := savingsRatio(spotinst.Float64Value(suggestion.RequestedCPU), spotinst.Float64Value(suggestion.SuggestedCPU))
+	memorySavings := savingsRatio(spotinst.Float64Value(suggestion.RequestedMemory), spotinst.Float64Value(suggestion.SuggestedMemory))
+
+	return monthlyCost * (cpuWeight*cpuSavings + (1-cpuWeight)*memorySavings)
+}
+
+// savingsRatio returns the share of requested that is saved if only suggested
+// is requested. Workloads without requests have no savings.
+func savingsRatio(requested, suggested float64) float64 {
+	if requested <= 0 {
+		return 0
+	}
+
+	return 1 - suggested/requested
+}
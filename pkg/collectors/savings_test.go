@@ -0,0 +1,55 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectedSavings(t *testing.T) {
+	tests := []struct {
+		name       string
+		cost       float64
+		cpuWeight  float64
+		suggestion [4]float64
+		expected   float64
+	}{
+		{
+			name:       "cpu and memory savings",
+			cost:       100,
+			cpuWeight:  DefaultSavingsCPUWeight,
+			suggestion: [4]float64{500, 1000, 1500, 2000},
+			expected:   37.5,
+		},
+		{
+			name:       "cpu only",
+			cost:       100,
+			cpuWeight:  1,
+			suggestion: [4]float64{500, 1000, 1500, 2000},
+			expected:   50,
+		},
+		{
+			name:       "under-provisioned",
+			cost:       50,
+			cpuWeight:  0.8,
+			suggestion: [4]float64{1200, 1000, 1000, 1000},
+			expected:   -8,
+		},
+		{
+			name:       "no requests",
+			cost:       100,
+			cpuWeight:  DefaultSavingsCPUWeight,
+			suggestion: [4]float64{500, 0, 500, 0},
+			expected:   0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := test.suggestion
+			suggestion := resourceSuggestion("foo", "deployment", "foo-ns", s[0], s[1], s[2], s[3])
+
+			assert.InDelta(t, test.expected, projectedSavings(test.cost, test.cpuWeight, suggestion), 1e-9)
+		})
+	}
+}
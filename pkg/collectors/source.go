@@ -0,0 +1,205 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/selfmetrics"
+	"github.com/go-logr/logr"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+var errNoSnapshot = errors.New("no data fetched yet")
+
+// fetchFunc fetches data of type T for a single Ocean cluster.
+type fetchFunc[T any] func(ctx context.Context, cluster *aws.Cluster) (T, error)
+
+// clusterSource provides per-cluster data to a collector.
+type clusterSource[T any] interface {
+	get(ctx context.Context, cluster *aws.Cluster) (T, error)
+}
+
+// newClusterSource returns a clusterSource which fetches data on every call to
+// get if the configured refresh interval is zero. Otherwise it returns a source
+// which refreshes the data of all clusters in the background until ctx is
+// cancelled, and serves the last successful snapshot.
+func newClusterSource[T any](
+	ctx context.Context,
+	logger logr.Logger,
+	clusters ClusterLister,
+	options *options,
+	fetch fetchFunc[T],
+) clusterSource[T] {
+	if options.refreshInterval <= 0 {
+		return fetch
+	}
+
+	source := &pollingSource[T]{
+		logger:    logger,
+		clusters:  clusters,
+		fetch:     fetch,
+		options:   options,
+		snapshots: make(map[string]T),
+	}
+
+	go source.run(ctx, options.refreshInterval)
+
+	return source
+}
+
+// result holds the data or error of a single cluster.
+type result[T any] struct {
+	data T
+	err  error
+}
+
+// getAll gets the data of all clusters from source using up to concurrency
+// parallel workers. The results are returned in the order of clusters.
+func getAll[T any](
+	ctx context.Context,
+	source clusterSource[T],
+	clusters []*aws.Cluster,
+	concurrency int,
+) []result[T] {
+	results := make([]result[T], len(clusters))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < min(max(concurrency, 1), len(clusters)); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				data, err := source.get(ctx, clusters[i])
+				results[i] = result[T]{data: data, err: err}
+			}
+		}()
+	}
+
+	for i := range clusters {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// logFetchError logs err, which occurred while fetching the data of cluster,
+// with msg. Clusters for which no snapshot was fetched yet, e.g. right after
+// startup or discovery, are only logged at debug level since this is expected
+// until the next background refresh completes.
+func logFetchError(logger logr.Logger, err error, msg string, cluster *aws.Cluster) {
+	clusterID := spotinst.StringValue(cluster.ID)
+
+	if errors.Is(err, errNoSnapshot) {
+		logger.V(1).Info("skipping cluster without data", "ocean_id", clusterID)
+		return
+	}
+
+	logger.Error(err, msg, "ocean_id", clusterID)
+}
+
+// instrument wraps fetch to record the outcome of every call in the metrics
+// for the given collector. Failed API calls are counted by the retrying
+// clients instead, since a single fetch may consist of several API calls.
+func (f fetchFunc[T]) instrument(metrics *selfmetrics.Metrics, collector string) fetchFunc[T] {
+	if metrics == nil {
+		return f
+	}
+
+	return func(ctx context.Context, cluster *aws.Cluster) (T, error) {
+		data, err := f(ctx, cluster)
+
+		metrics.ObserveClusterFetch(collector, cluster, err)
+
+		return data, err
+	}
+}
+
+// get implements clusterSource by fetching the data on demand.
+func (f fetchFunc[T]) get(ctx context.Context, cluster *aws.Cluster) (T, error) {
+	return f(ctx, cluster)
+}
+
+// pollingSource is a clusterSource which serves snapshots of per-cluster data
+// that are refreshed in the background, decoupled from Prometheus scrapes.
+type pollingSource[T any] struct {
+	logger    logr.Logger
+	clusters  ClusterLister
+	fetch     fetchFunc[T]
+	options   *options
+	mu        sync.RWMutex
+	snapshots map[string]T
+}
+
+func (s *pollingSource[T]) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches the data for all clusters. Snapshots of clusters that fail
+// to refresh are retained, snapshots of clusters that disappeared are dropped.
+func (s *pollingSource[T]) refresh(ctx context.Context) {
+	ctx, cancel := s.options.refreshContext(ctx)
+	defer cancel()
+
+	clusters := s.clusters.Clusters()
+	results := getAll[T](ctx, s.fetch, clusters, s.options.concurrency)
+	snapshots := make(map[string]T, len(clusters))
+
+	for i, cluster := range clusters {
+		clusterID := spotinst.StringValue(cluster.ID)
+
+		data, err := results[i].data, results[i].err
+		if err != nil {
+			s.logger.Error(err, "failed to refresh cluster data", "ocean_id", clusterID)
+
+			s.mu.RLock()
+			previous, ok := s.snapshots[clusterID]
+			s.mu.RUnlock()
+
+			if !ok {
+				continue
+			}
+
+			data = previous
+		}
+
+		snapshots[clusterID] = data
+	}
+
+	s.mu.Lock()
+	s.snapshots = snapshots
+	s.mu.Unlock()
+}
+
+// get implements clusterSource by returning the last successful snapshot.
+func (s *pollingSource[T]) get(_ context.Context, cluster *aws.Cluster) (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.snapshots[spotinst.StringValue(cluster.ID)]
+	if !ok {
+		return data, errNoSnapshot
+	}
+
+	return data, nil
+}
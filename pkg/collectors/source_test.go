@@ -0,0 +1,171 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/go-logr/zapr"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPollingSource(t *testing.T) {
+	ctx := context.Background()
+	logger := zapr.NewLogger(zap.NewNop())
+
+	inventory := clusters.Static(testhelpers.OceanClusters("foo", "bar"))
+	results := map[string]error{}
+	calls := 0
+
+	source := &pollingSource[int]{
+		logger:   logger,
+		clusters: &inventory,
+		fetch: func(_ context.Context, cluster *aws.Cluster) (int, error) {
+			calls++
+			return calls, results[spotinst.StringValue(cluster.ID)]
+		},
+		options:   newOptions(),
+		snapshots: make(map[string]int),
+	}
+
+	foo, bar := testhelpers.OceanClusters("foo")[0], testhelpers.OceanClusters("bar")[0]
+
+	_, err := source.get(ctx, foo)
+	assert.ErrorIs(t, err, errNoSnapshot)
+
+	source.refresh(ctx)
+
+	value, err := source.get(ctx, foo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	value, err = source.get(ctx, bar)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+
+	// Failed refreshes retain the previous snapshot.
+	results["foo"] = errors.New("unavailable")
+	source.refresh(ctx)
+
+	value, err = source.get(ctx, foo)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	value, err = source.get(ctx, bar)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, value)
+
+	// Snapshots of clusters that disappeared are dropped.
+	inventory = clusters.Static(testhelpers.OceanClusters("bar"))
+	source.refresh(ctx)
+
+	_, err = source.get(ctx, foo)
+	assert.ErrorIs(t, err, errNoSnapshot)
+
+	value, err = source.get(ctx, bar)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, value)
+}
+
+func TestPollingSourceRefreshTimeout(t *testing.T) {
+	inventory := clusters.Static(testhelpers.OceanClusters("foo"))
+
+	var deadline time.Time
+
+	source := &pollingSource[int]{
+		logger:   zapr.NewLogger(zap.NewNop()),
+		clusters: &inventory,
+		fetch: func(ctx context.Context, _ *aws.Cluster) (int, error) {
+			deadline, _ = ctx.Deadline()
+			return 1, ctx.Err()
+		},
+		// Background refreshes are not limited by the scrape timeout.
+		options:   newOptions(WithScrapeTimeout(time.Nanosecond), WithRefreshTimeout(time.Hour)),
+		snapshots: make(map[string]int),
+	}
+
+	source.refresh(context.Background())
+
+	value, err := source.get(context.Background(), testhelpers.OceanClusters("foo")[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+}
+
+func TestLogFetchError(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zapr.NewLogger(zap.New(core))
+	cluster := testhelpers.OceanClusters("foo")[0]
+
+	// Clusters without a snapshot yet are expected and not logged as errors.
+	logFetchError(logger, errNoSnapshot, "failed to fetch", cluster)
+
+	entries := logs.TakeAll()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	}
+
+	logFetchError(logger, errors.New("unavailable"), "failed to fetch", cluster)
+
+	entries = logs.TakeAll()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+		assert.Equal(t, "failed to fetch", entries[0].Message)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	ctx := context.Background()
+	clusterIDs := []string{"a", "b", "c", "d", "e", "f", "g"}
+
+	var running, maxRunning atomic.Int32
+
+	fetch := fetchFunc[string](func(_ context.Context, cluster *aws.Cluster) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		clusterID := spotinst.StringValue(cluster.ID)
+		if clusterID == "c" {
+			return "", errors.New("unavailable")
+		}
+
+		return clusterID, nil
+	})
+
+	for _, concurrency := range []int{0, 1, 3, 20} {
+		maxRunning.Store(0)
+
+		results := getAll[string](ctx, fetch, testhelpers.OceanClusters(clusterIDs...), concurrency)
+
+		assert.Len(t, results, len(clusterIDs))
+		assert.LessOrEqual(t, maxRunning.Load(), int32(max(concurrency, 1)))
+
+		for i, clusterID := range clusterIDs {
+			if clusterID == "c" {
+				assert.Error(t, results[i].err)
+				continue
+			}
+
+			assert.NoError(t, results[i].err)
+			assert.Equal(t, clusterID, results[i].data)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package collectors
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+// workloadKey identifies a workload within a cluster.
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+// indexedWorkload holds the data recorded for a single workload.
+type indexedWorkload struct {
+	labels map[string]string
+	// monthlyCost is the month-to-date cost extrapolated to the whole month,
+	// see monthlyCostFactor.
+	monthlyCost float64
+}
+
+// clusterWorkloads holds the data recorded for the namespaces and workloads
+// of a single cluster.
+type clusterWorkloads struct {
+	namespaceLabels map[string]map[string]string
+	workloads       map[workloadKey]indexedWorkload
+}
+
+// WorkloadIndex holds the Kubernetes labels and monthly costs of the
+// namespaces and workloads of Ocean clusters. Resource suggestions carry
+// neither, so they are recorded by the OceanAWSClusterCostsCollector from the
+// month-to-date costs and looked up by the
+// OceanAWSResourceSuggestionsCollector. It is safe for concurrent use.
+type WorkloadIndex struct {
+	mu       sync.RWMutex
+	clusters map[string]clusterWorkloads
+}
+
+// NewWorkloadIndex creates a new, empty WorkloadIndex.
+func NewWorkloadIndex() *WorkloadIndex {
+	return &WorkloadIndex{clusters: make(map[string]clusterWorkloads)}
+}
+
+// update replaces the data of cluster with the namespaces and workloads in
+// the month-to-date costs output fetched at now. It is a no-op if w is nil.
+func (w *WorkloadIndex) update(cluster *aws.Cluster, output *mcs.ClusterCostOutput, now time.Time) {
+	if w == nil || output == nil {
+		return
+	}
+
+	factor := monthlyCostFactor(now)
+	data := clusterWorkloads{
+		namespaceLabels: make(map[string]map[string]string),
+		workloads:       make(map[workloadKey]indexedWorkload),
+	}
+
+	for _, clusterCost := range output.ClusterCosts {
+		for _, namespace := range clusterCost.Namespaces {
+			name := spotinst.StringValue(namespace.Namespace)
+			data.namespaceLabels[name] = namespace.Labels
+
+			for kind, resources := range workloadResources(namespace) {
+				for _, resource := range resources {
+					key := workloadKey{namespace: name, kind: kind, name: spotinst.StringValue(resource.Name)}
+
+					workload := data.workloads[key]
+					workload.labels = resource.Labels
+					workload.monthlyCost += spotinst.Float64Value(resource.Cost) * factor
+					data.workloads[key] = workload
+				}
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.clusters[spotinst.StringValue(cluster.ID)] = data
+}
+
+// retain drops the data of all clusters not in clusters. It is a no-op if w
+// is nil.
+func (w *WorkloadIndex) retain(clusters []*aws.Cluster) {
+	if w == nil {
+		return
+	}
+
+	retain := make(map[string]struct{}, len(clusters))
+	for _, cluster := range clusters {
+		retain[spotinst.StringValue(cluster.ID)] = struct{}{}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for clusterID := range w.clusters {
+		if _, ok := retain[clusterID]; !ok {
+			delete(w.clusters, clusterID)
+		}
+	}
+}
+
+// ready reports whether the data of cluster was recorded yet. It is always
+// true if w is nil, since there is nothing to wait for then.
+func (w *WorkloadIndex) ready(cluster *aws.Cluster) bool {
+	if w == nil {
+		return true
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	_, ok := w.clusters[spotinst.StringValue(cluster.ID)]
+
+	return ok
+}
+
+// get returns the data of a workload and the labels of its namespace. The
+// kind is matched case-insensitively. found is false if w is nil or the
+// workload is unknown.
+func (w *WorkloadIndex) get(
+	cluster *aws.Cluster,
+	namespace, kind, name string,
+) (workload indexedWorkload, namespaceLabels map[string]string, found bool) {
+	if w == nil {
+		return workload, nil, false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	data, ok := w.clusters[spotinst.StringValue(cluster.ID)]
+	if !ok {
+		return workload, nil, false
+	}
+
+	workload, found = data.workloads[workloadKey{namespace: namespace, kind: strings.ToLower(kind), name: name}]
+
+	return workload, data.namespaceLabels[namespace], found
+}
+
+// workloadResources returns the resources of namespace by workload kind.
+func workloadResources(namespace *mcs.Namespace) map[string][]*mcs.Resource {
+	return map[string][]*mcs.Resource{
+		workloadDeployment:  namespace.Deployments,
+		workloadDaemonSet:   namespace.DaemonSets,
+		workloadStatefulSet: namespace.StatefulSets,
+		workloadJob:         namespace.Jobs,
+	}
+}
@@ -0,0 +1,152 @@
+package collectors
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/clusters"
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/labels"
+	"github.com/go-logr/zapr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/mcs"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestWorkloadIndex(t *testing.T) {
+	foo, bar := testhelpers.OceanClusters("foo")[0], testhelpers.OceanClusters("bar")[0]
+
+	// Half of March has elapsed, so the monthly costs are twice the
+	// month-to-date costs.
+	now := fixedClock("2024-03-16T12:00:00Z")()
+
+	namespace := namespaceCostLabels(
+		"foo-ns", 100,
+		map[string]string{"env": "prod"},
+		resourceCostLabels("foo-ns", "foo-app", 50, map[string]string{"team": "foo-team"}),
+		resourceCostLabels("foo-ns", "foo-app", 10, map[string]string{"team": "foo-team"}),
+	)
+	namespace.StatefulSets = []*mcs.Resource{
+		resourceCostLabels("foo-ns", "foo-app", 40, map[string]string{"team": "db-team"}),
+	}
+
+	index := NewWorkloadIndex()
+	index.update(foo, clusterCostOutput(100, namespace), now)
+
+	workload, namespaceLabels, found := index.get(foo, "foo-ns", "Deployment", "foo-app")
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"team": "foo-team"}, workload.labels)
+	assert.InDelta(t, 120, workload.monthlyCost, 1e-9)
+	assert.Equal(t, map[string]string{"env": "prod"}, namespaceLabels)
+
+	workload, _, found = index.get(foo, "foo-ns", "statefulSet", "foo-app")
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"team": "db-team"}, workload.labels)
+	assert.InDelta(t, 80, workload.monthlyCost, 1e-9)
+
+	workload, namespaceLabels, found = index.get(foo, "foo-ns", "daemonSet", "foo-app")
+	assert.False(t, found)
+	assert.Nil(t, workload.labels)
+	assert.Equal(t, map[string]string{"env": "prod"}, namespaceLabels)
+
+	_, namespaceLabels, found = index.get(bar, "foo-ns", "deployment", "foo-app")
+	assert.False(t, found)
+	assert.Nil(t, namespaceLabels)
+	assert.True(t, index.ready(foo))
+	assert.False(t, index.ready(bar))
+
+	// Clusters that left the inventory are dropped.
+	index.update(bar, clusterCostOutput(100, namespace), now)
+	index.retain([]*aws.Cluster{foo})
+
+	_, _, found = index.get(bar, "foo-ns", "deployment", "foo-app")
+	assert.False(t, found)
+	assert.False(t, index.ready(bar))
+
+	// Updates replace all data of a cluster.
+	index.update(foo, clusterCostOutput(0), now)
+
+	_, namespaceLabels, found = index.get(foo, "foo-ns", "deployment", "foo-app")
+	assert.False(t, found)
+	assert.Nil(t, namespaceLabels)
+
+	var disabled *WorkloadIndex
+	disabled.update(foo, clusterCostOutput(100, namespace), now)
+	assert.True(t, disabled.ready(foo))
+
+	_, namespaceLabels, found = disabled.get(foo, "foo-ns", "deployment", "foo-app")
+	assert.False(t, found)
+	assert.Nil(t, namespaceLabels)
+}
+
+func TestOceanAWSClusterCostsCollectorUpdatesWorkloadIndex(t *testing.T) {
+	logger := zapr.NewLogger(zap.NewNop())
+
+	mockClient := new(mockOceanAWSClusterCostsClient)
+	mockClient.On("GetClusterCosts", mock.Anything, clusterCostInputWindow("foo", CostWindowToday)).Return(clusterCostOutput(
+		2,
+		namespaceCost("foo-ns", 2, resourceCost("foo-ns", "foo-app", 2)),
+	), nil)
+	mockClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+		20,
+		namespaceCost("foo-ns", 20, resourceCostLabels("foo-ns", "foo-app", 20, map[string]string{"team": "foo-team"})),
+	), nil)
+
+	index := NewWorkloadIndex()
+	collector := NewOceanAWSClusterCostsCollector(
+		context.Background(), logger, mockClient, clusters.Static(testhelpers.OceanClusters("foo")), nil,
+		WithCostWindows(CostWindowToday),
+		WithWorkloadIndex(index),
+	)
+
+	// Only the configured cost window is collected, but the month-to-date
+	// costs are fetched to update the workload index.
+	assert.Equal(t, 3, testutil.CollectAndCount(collector, "spotinst_ocean_aws_cluster_cost_today", "spotinst_ocean_aws_namespace_cost_today", "spotinst_ocean_aws_workload_cost_today"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "spotinst_ocean_aws_cluster_cost"))
+
+	workload, _, found := index.get(testhelpers.OceanClusters("foo")[0], "foo-ns", "deployment", "foo-app")
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"team": "foo-team"}, workload.labels)
+	mockClient.AssertExpectations(t)
+}
+
+func TestOceanAWSResourceSuggestionsCollectorWaitsForWorkloadIndex(t *testing.T) {
+	logger := zapr.NewLogger(zap.NewNop())
+	ctx := context.Background()
+	inventory := clusters.Static(testhelpers.OceanClusters("foo"))
+	mappings, _ := labels.ParseMappings("team")
+
+	costsClient := new(mockOceanAWSClusterCostsClient)
+	costsClient.On("GetClusterCosts", mock.Anything, clusterCostInput("foo")).Return(clusterCostOutput(
+		20,
+		namespaceCost("foo-ns", 20, resourceCostLabels("foo-ns", "foo-app", 20, map[string]string{"team": "foo-team"})),
+	), nil)
+
+	suggestionsClient := new(mockOceanAWSResourceSuggestionsClient)
+	suggestionsClient.On("ListOceanResourceSuggestions", mock.Anything, resourceSuggestionsInput("foo")).Return(
+		resourceSuggestionsOutput(resourceSuggestion("foo-app", "deployment", "foo-ns", 200, 1000, 100, 2000)),
+		nil,
+	)
+
+	index := NewWorkloadIndex()
+	costs := NewOceanAWSClusterCostsCollector(ctx, logger, costsClient, inventory, mappings, WithWorkloadIndex(index))
+	suggestions := NewOceanAWSResourceSuggestionsCollector(ctx, logger, suggestionsClient, inventory, mappings, WithWorkloadIndex(index))
+
+	// The cluster is skipped while the index is cold instead of exporting
+	// series without labels, which would be replaced on the next scrape.
+	assert.Equal(t, 0, testutil.CollectAndCount(suggestions))
+
+	testutil.CollectAndCount(costs)
+
+	expected := `
+		# HELP spotinst_ocean_aws_workload_cpu_requested The number of actual CPU units requested by a workload
+		# TYPE spotinst_ocean_aws_workload_cpu_requested gauge
+		spotinst_ocean_aws_workload_cpu_requested{name="foo-app",namespace="foo-ns",ocean_id="foo",ocean_name="ocean-foo",team="foo-team",workload="deployment"} 1000
+	`
+
+	assert.NoError(t, testutil.CollectAndCompare(suggestions, strings.NewReader(expected), "spotinst_ocean_aws_workload_cpu_requested"))
+}
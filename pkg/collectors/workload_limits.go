@@ -0,0 +1,175 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// otherWorkloadName is the name of the series the costs of workloads exceeding
+// the workload limits are summed up into.
+const otherWorkloadName = "__other__"
+
+// WorkloadLimits limits the number of workloads per workload kind for which
+// costs are exported individually. The limit for the empty kind applies to
+// all kinds without a dedicated limit. A limit of zero means no limit.
+type WorkloadLimits map[string]int
+
+// ParseWorkloadLimits parses a comma-separated list of limits in the format
+// `[kind=]limit`, e.g. `50,job=10`.
+//
+// Returns an error if the input is malformed, a kind is unknown or a limit is
+// negative.
+func ParseWorkloadLimits(input string) (WorkloadLimits, error) {
+	limits := make(WorkloadLimits)
+
+	for _, entry := range strings.Split(input, ",") {
+		kind, value, found := strings.Cut(entry, "=")
+		if !found {
+			kind, value = "", entry
+		} else if !isWorkloadKind(kind) {
+			return nil, fmt.Errorf("invalid workload limit %q: unknown workload kind %q", entry, kind)
+		}
+
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid workload limit %q: limit must be a non-negative integer", entry)
+		}
+
+		limits[kind] = limit
+	}
+
+	return limits, nil
+}
+
+// limit returns the limit for kind, or zero if there is none.
+func (l WorkloadLimits) limit(kind string) int {
+	if limit, ok := l[kind]; ok {
+		return limit
+	}
+
+	return l[""]
+}
+
+// Set implements the pflag.Value interface.
+func (l *WorkloadLimits) Set(value string) error {
+	limits, err := ParseWorkloadLimits(value)
+	if err != nil {
+		return err
+	}
+
+	if *l == nil {
+		*l = make(WorkloadLimits, len(limits))
+	}
+
+	for kind, limit := range limits {
+		(*l)[kind] = limit
+	}
+
+	return nil
+}
+
+// String implements the pflag.Value interface.
+func (l WorkloadLimits) String() string {
+	entries := make([]string, 0, len(l))
+
+	for kind, limit := range l {
+		if kind == "" {
+			entries = append(entries, strconv.Itoa(limit))
+		} else {
+			entries = append(entries, kind+"="+strconv.Itoa(limit))
+		}
+	}
+
+	sort.Strings(entries)
+
+	return strings.Join(entries, ",")
+}
+
+// Type implements the pflag.Value interface.
+func (l WorkloadLimits) Type() string {
+	return "[kind=]limit"
+}
+
+// workloadSeries holds the workload cost series of a single namespace and
+// workload kind.
+type workloadSeries struct {
+	kind                 string
+	namespaceLabelValues []string
+	// otherLabelValues are the mapped label values of the otherWorkloadName
+	// series.
+	otherLabelValues []string
+	seriesSet
+	// infoLabelValues holds the info label values of each series, taken
+	// from the first workload added to it.
+	infoLabelValues [][]string
+	// dropped marks the series exceeding the workload limits.
+	dropped []bool
+}
+
+// otherCost returns the summed up costs of the dropped series and whether
+// there are any.
+func (w *workloadSeries) otherCost() (float64, bool) {
+	var cost float64
+	var found bool
+
+	for i, dropped := range w.dropped {
+		if dropped {
+			cost += w.series[i].value
+			found = true
+		}
+	}
+
+	return cost, found
+}
+
+// workloadRef references a single series of a workloadSeries.
+type workloadRef struct {
+	workloads *workloadSeries
+	index     int
+}
+
+func (r workloadRef) value() float64 {
+	return r.workloads.series[r.index].value
+}
+
+// applyWorkloadLimits marks all but the most expensive series as dropped,
+// first per namespace and kind, then per cluster and kind.
+func applyWorkloadLimits(workloads []*workloadSeries, perNamespace, perCluster WorkloadLimits) {
+	clusterRefs := make(map[string][]workloadRef)
+
+	for _, w := range workloads {
+		w.dropped = make([]bool, len(w.series))
+
+		refs := make([]workloadRef, len(w.series))
+		for i := range w.series {
+			refs[i] = workloadRef{workloads: w, index: i}
+		}
+
+		refs = dropExceeding(refs, perNamespace.limit(w.kind))
+		clusterRefs[w.kind] = append(clusterRefs[w.kind], refs...)
+	}
+
+	for kind, refs := range clusterRefs {
+		dropExceeding(refs, perCluster.limit(kind))
+	}
+}
+
+// dropExceeding marks all but the limit most expensive series as dropped and
+// returns the remaining ones. Ties are resolved by the order of refs.
+func dropExceeding(refs []workloadRef, limit int) []workloadRef {
+	if limit <= 0 || len(refs) <= limit {
+		return refs
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].value() > refs[j].value()
+	})
+
+	for _, ref := range refs[limit:] {
+		ref.workloads.dropped[ref.index] = true
+	}
+
+	return refs[:limit]
+}
@@ -0,0 +1,85 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkloadLimits(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    WorkloadLimits
+		expectedErr string
+	}{
+		{input: "50", expected: WorkloadLimits{"": 50}},
+		{input: "50,job=10", expected: WorkloadLimits{"": 50, "job": 10}},
+		{input: "deployment=0", expected: WorkloadLimits{"deployment": 0}},
+		{input: "pod=10", expectedErr: `unknown workload kind "pod"`},
+		{input: "job=ten", expectedErr: "must be a non-negative integer"},
+		{input: "-1", expectedErr: "must be a non-negative integer"},
+		{input: "", expectedErr: "must be a non-negative integer"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			limits, err := ParseWorkloadLimits(testCase.input)
+			if testCase.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, limits)
+		})
+	}
+}
+
+func TestWorkloadLimitsSet(t *testing.T) {
+	var limits WorkloadLimits
+	require.NoError(t, limits.Set("50,job=10"))
+	require.NoError(t, limits.Set("job=5"))
+
+	assert.Equal(t, 50, limits.limit(workloadDeployment))
+	assert.Equal(t, 5, limits.limit(workloadJob))
+	assert.Equal(t, "50,job=5", limits.String())
+
+	var unset WorkloadLimits
+	assert.Equal(t, 0, unset.limit(workloadJob))
+}
+
+func TestApplyWorkloadLimits(t *testing.T) {
+	newWorkloads := func(kind, namespace string, costs ...float64) *workloadSeries {
+		w := &workloadSeries{kind: kind, namespaceLabelValues: []string{namespace}}
+		for i, cost := range costs {
+			w.add([]string{namespace, string(rune('a' + i)), kind}, cost)
+		}
+
+		return w
+	}
+
+	foo := newWorkloads(workloadDeployment, "foo", 1, 5, 3, 4)
+	bar := newWorkloads(workloadDeployment, "bar", 2, 6)
+	jobs := newWorkloads(workloadJob, "foo", 1, 2, 3)
+
+	applyWorkloadLimits(
+		[]*workloadSeries{foo, bar, jobs},
+		WorkloadLimits{workloadDeployment: 3},
+		WorkloadLimits{"": 3},
+	)
+
+	// The cheapest deployment of foo exceeds the namespace limit, the
+	// deployment costing 3 the cluster limit.
+	assert.Equal(t, []bool{true, false, true, false}, foo.dropped)
+	assert.Equal(t, []bool{true, false}, bar.dropped)
+	assert.Equal(t, []bool{false, false, false}, jobs.dropped)
+
+	cost, ok := foo.otherCost()
+	assert.True(t, ok)
+	assert.Equal(t, 4.0, cost)
+
+	_, ok = jobs.otherCost()
+	assert.False(t, ok)
+}
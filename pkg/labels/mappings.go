@@ -2,16 +2,38 @@ package labels
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-var errEmptyLabelName = errors.New("label names must not be empty")
+var (
+	errEmptyLabelName     = errors.New("label names must not be empty")
+	errMissingTargetNames = errors.New("pattern mappings require a list of Prometheus label names, e.g. 'team.example.com/*[owner,cost_center]'")
+	errInvalidPattern     = errors.New("prefix patterns may only contain a single trailing '*'")
+)
+
+// defaultTemplate renames the resource labels matched by a pattern to the
+// text matched by the pattern's first capture group, which is everything
+// after the prefix for prefix patterns.
+const defaultTemplate = "$1"
 
 // Mapping defines a mapping between a Kubernetes resource label and a
 // Prometheus label.
+//
+// Pattern mappings select the resource label via a regular expression instead
+// and derive the Prometheus label name from it using a template. A pattern
+// mapping only produces a value for the resource label whose derived name is
+// equal to its Prometheus label name.
 type Mapping struct {
 	resourceLabelName   string
 	prometheusLabelName string
+	pattern             *regexp.Regexp
+	template            string
+	// transforms are applied to the label value in order.
+	transforms []transform
 }
 
 // Mappings is a list of label mappings.
@@ -19,33 +41,286 @@ type Mappings []Mapping
 
 // ParseMappings parses label mappings from an input string.
 //
-// Returns an error if the input is malformed.
+// If no Prometheus label is given for a resource label, the resource label
+// name is sanitized to be a valid Prometheus label name, e.g.
+// `app.kubernetes.io/name` is mapped to `app_kubernetes_io_name`.
+//
+// Entries in the format `selector[=template][name,...]` are pattern mappings.
+// The selector is either a prefix followed by `*`, e.g. `team.example.com/*`,
+// or a regular expression prefixed with `~`, which must not contain commas,
+// `=` or `;`. The Prometheus label name of each matching resource label is
+// derived from the template, which may reference capture groups (`$1` for the
+// text matched by `*`, which is also the default template), and sanitized.
+// References to capture groups the pattern doesn't have are rejected. Like in
+// regexp.Regexp.Expand, `${1}` must be used if a reference is followed by a
+// letter, digit or underscore. Only the label names listed in brackets are
+// propagated, which keeps the set of Prometheus labels fixed.
+//
+// Each entry may be followed by a `;`-separated list of transforms which are
+// applied to the label values in order, e.g. `team;trim;lower;default=none`.
+// See parseTransform for the supported transforms. An unescaped comma always
+// starts a new entry, so commas and `;` in transform arguments, e.g. in the
+// value of `default=` or in regular expressions, must be escaped as `\,` and
+// `\;`.
+//
+// Returns an error if the input is malformed, a Prometheus label name is
+// invalid, collides with a label set by the exporter or is used more than
+// once.
 func ParseMappings(input string) (Mappings, error) {
-	pairs := strings.Split(input, ",")
-	mappings := make(Mappings, 0, len(pairs))
+	entries := splitEntries(input)
+	mappings := make(Mappings, 0, len(entries))
+
+	for _, entry := range entries {
+		var entryMappings Mappings
+		var err error
 
-	for _, pair := range pairs {
-		labels := strings.SplitN(pair, "=", 2)
+		spec, transformSpecs, hasTransforms := cutUnescaped(entry, ';')
+
+		if isPattern(spec) {
+			entryMappings, err = parsePatternMapping(spec)
+		} else {
+			entryMappings, err = parseMapping(spec)
+		}
 
-		resourceLabel := labels[0]
-		prometheusLabel := resourceLabel
-		if len(labels) == 2 {
-			prometheusLabel = labels[1]
+		if err != nil {
+			return nil, err
 		}
 
-		if resourceLabel == "" || prometheusLabel == "" {
-			return nil, errEmptyLabelName
+		var transforms []transform
+		if hasTransforms {
+			transforms, err = parseTransforms(transformSpecs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid label mapping %q: %w", entry, err)
+			}
 		}
 
+		for i, mapping := range entryMappings {
+			if err := ValidateName(mapping.prometheusLabelName); err != nil {
+				return nil, fmt.Errorf("invalid label mapping %q: %w", entry, err)
+			}
+
+			entryMappings[i].transforms = transforms
+		}
+
+		mappings = append(mappings, entryMappings...)
+	}
+
+	if err := ValidateUnique(mappings.LabelNames()); err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func parseMapping(entry string) (Mappings, error) {
+	labels := strings.SplitN(entry, "=", 2)
+
+	resourceLabel := labels[0]
+	prometheusLabel := SanitizeName(resourceLabel)
+	if len(labels) == 2 {
+		prometheusLabel = labels[1]
+	}
+
+	if resourceLabel == "" || prometheusLabel == "" {
+		return nil, errEmptyLabelName
+	}
+
+	return Mappings{{
+		resourceLabelName:   resourceLabel,
+		prometheusLabelName: prometheusLabel,
+	}}, nil
+}
+
+func parsePatternMapping(entry string) (Mappings, error) {
+	start := strings.LastIndex(entry, "[")
+	if start < 0 || !strings.HasSuffix(entry, "]") {
+		return nil, fmt.Errorf("invalid label mapping %q: %w", entry, errMissingTargetNames)
+	}
+
+	selector, template, found := cutLast(entry[:start], "=")
+	if !found {
+		template = defaultTemplate
+	}
+
+	if template == "" {
+		return nil, errEmptyLabelName
+	}
+
+	pattern, err := compileSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label mapping %q: %w", entry, err)
+	}
+
+	if err := validateTemplate(pattern, template); err != nil {
+		return nil, fmt.Errorf("invalid label mapping %q: %w", entry, err)
+	}
+
+	names := strings.Split(entry[start+1:len(entry)-1], ",")
+	mappings := make(Mappings, 0, len(names))
+
+	for _, name := range names {
 		mappings = append(mappings, Mapping{
-			resourceLabelName:   resourceLabel,
-			prometheusLabelName: prometheusLabel,
+			resourceLabelName:   selector,
+			prometheusLabelName: name,
+			pattern:             pattern,
+			template:            template,
 		})
 	}
 
 	return mappings, nil
 }
 
+// compileSelector converts the selector of a pattern mapping into a regular
+// expression.
+func compileSelector(selector string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(selector, "~"); ok {
+		return regexp.Compile(expr)
+	}
+
+	prefix, ok := strings.CutSuffix(selector, "*")
+	if !ok || strings.Contains(prefix, "*") {
+		return nil, errInvalidPattern
+	}
+
+	return regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "(.*)$"), nil
+}
+
+// validateTemplate returns an error if template references a capture group
+// that pattern does not have, which would always expand to the empty string.
+// References follow the syntax of regexp.Regexp.Expand, so `$1_x` refers to
+// the group named `1_x` rather than `$1` followed by `_x`.
+func validateTemplate(pattern *regexp.Regexp, template string) error {
+	for rest := template; ; {
+		i := strings.Index(rest, "$")
+		if i < 0 || i == len(rest)-1 {
+			return nil
+		}
+
+		rest = rest[i+1:]
+
+		var name string
+
+		switch {
+		case rest[0] == '$':
+			rest = rest[1:]
+			continue
+		case rest[0] == '{':
+			end := strings.Index(rest, "}")
+			if end < 0 {
+				continue
+			}
+
+			name, rest = rest[1:end], rest[end+1:]
+		default:
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+
+			name, rest = rest[:end], rest[end:]
+		}
+
+		if name == "" {
+			continue
+		}
+
+		if index, err := strconv.Atoi(name); err == nil {
+			if index > pattern.NumSubexp() {
+				return fmt.Errorf("template %q references capture group $%d, but the pattern only has %d", template, index, pattern.NumSubexp())
+			}
+
+			continue
+		}
+
+		if pattern.SubexpIndex(name) < 0 {
+			return fmt.Errorf("template %q references unknown capture group %q, use braces like ${1} to separate a reference from the text following it", template, name)
+		}
+	}
+}
+
+func isPattern(entry string) bool {
+	selector, _, _ := strings.Cut(entry, "=")
+
+	return strings.HasPrefix(entry, "~") || strings.Contains(selector, "*") || strings.HasSuffix(entry, "]")
+}
+
+// splitEntries splits input at all commas which are neither escaped nor
+// enclosed in brackets.
+func splitEntries(input string) []string {
+	var entries []string
+	var depth, start int
+	var escaped bool
+
+	for i, r := range input {
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		switch r {
+		case '\\':
+			escaped = true
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				entries = append(entries, input[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(entries, input[start:])
+}
+
+// splitUnescaped splits s at all occurrences of sep which are not preceded
+// by a backslash. Escape sequences are kept.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// cutUnescaped slices s around the first occurrence of sep which is not
+// preceded by a backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	parts := splitUnescaped(s, sep)
+	if len(parts) == 1 {
+		return s, "", false
+	}
+
+	return parts[0], s[len(parts[0])+1:], true
+}
+
+// entryEscaper escapes the characters separating entries and transforms.
+var entryEscaper = strings.NewReplacer(",", "\\,", ";", "\\;")
+
+// entryUnescaper reverses entryEscaper.
+var entryUnescaper = strings.NewReplacer("\\,", ",", "\\;", ";")
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
 // LabelNames returns the names of the Prometheus labels.
 func (m Mappings) LabelNames() []string {
 	values := make([]string, 0, len(m))
@@ -58,17 +333,75 @@ func (m Mappings) LabelNames() []string {
 }
 
 // LabelValues extracts the values for the configured Prometheus labels from
-// the provided labels map.
-func (m Mappings) LabelValues(labels map[string]string) []string {
-	values := make([]string, 0, len(m))
+// the provided labels maps. If a label is missing or empty in a map, the
+// value is looked up in the next one. The transforms of the mappings are
+// applied to the resulting values.
+//
+// If multiple resource labels match a pattern mapping, the value of the first
+// one in lexicographical order is used.
+func (m Mappings) LabelValues(labelMaps ...map[string]string) []string {
+	values := make([]string, len(m))
 
-	for _, mapping := range m {
-		values = append(values, labels[mapping.resourceLabelName])
+	for _, labels := range labelMaps {
+		var keys []string
+
+		for i, mapping := range m {
+			if values[i] != "" {
+				continue
+			}
+
+			if mapping.pattern == nil {
+				values[i] = labels[mapping.resourceLabelName]
+				continue
+			}
+
+			if keys == nil {
+				keys = sortedKeys(labels)
+			}
+
+			values[i] = mapping.matchValue(keys, labels)
+		}
+	}
+
+	for i, mapping := range m {
+		for _, t := range mapping.transforms {
+			values[i] = t.apply(values[i])
+		}
 	}
 
 	return values
 }
 
+// matchValue returns the value of the first resource label in keys whose
+// derived Prometheus label name is equal to the mapping's label name.
+func (m Mapping) matchValue(keys []string, labels map[string]string) string {
+	for _, key := range keys {
+		match := m.pattern.FindStringSubmatchIndex(key)
+		if match == nil {
+			continue
+		}
+
+		name := SanitizeName(string(m.pattern.ExpandString(nil, m.template, key, match)))
+		if name == m.prometheusLabelName {
+			return labels[key]
+		}
+	}
+
+	return ""
+}
+
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
 // Set implements pflag.Value.
 func (m *Mappings) Set(value string) error {
 	mappings, err := ParseMappings(value)
@@ -76,6 +409,10 @@ func (m *Mappings) Set(value string) error {
 		return err
 	}
 
+	if err := ValidateUnique(m.LabelNames(), mappings.LabelNames()); err != nil {
+		return err
+	}
+
 	*m = append(*m, mappings...)
 	return nil
 }
@@ -85,17 +422,54 @@ func (m Mappings) String() string {
 	var sb strings.Builder
 
 	for i, mapping := range m {
+		if mapping.pattern != nil && i > 0 && m[i-1].pattern == mapping.pattern {
+			// Consecutive mappings sharing a pattern originate from the same
+			// entry and are rendered as a single one.
+			continue
+		}
+
 		if i > 0 {
 			sb.WriteRune(',')
 		}
+
 		sb.WriteString(mapping.resourceLabelName)
 		sb.WriteRune('=')
-		sb.WriteString(mapping.prometheusLabelName)
+
+		if mapping.pattern == nil {
+			sb.WriteString(mapping.prometheusLabelName)
+			writeTransforms(&sb, mapping.transforms)
+			continue
+		}
+
+		sb.WriteString(mapping.template)
+		sb.WriteRune('[')
+
+		for j, other := range m[i:] {
+			if other.pattern != mapping.pattern {
+				break
+			}
+
+			if j > 0 {
+				sb.WriteRune(',')
+			}
+
+			sb.WriteString(other.prometheusLabelName)
+		}
+
+		sb.WriteRune(']')
+		writeTransforms(&sb, mapping.transforms)
 	}
 
 	return sb.String()
 }
 
+func writeTransforms(sb *strings.Builder, transforms []transform) {
+	for _, t := range transforms {
+		sb.WriteRune(';')
+		sb.WriteString(entryEscaper.Replace(t.spec))
+	}
+}
+
 // Type implements pflag.Value.
 func (m Mappings) Type() string {
 	return "resource-label[=prometheus-label]"
@@ -26,8 +26,132 @@ func TestMappings(t *testing.T) {
 		assert.Equal(t, "foo=foo,bar=baz", mappings.String())
 	})
 
+	t.Run("fallback label values", func(t *testing.T) {
+		mappings, err := ParseMappings("foo,bar=baz,team.example.com/*[owner]")
+		assert.NoError(t, err)
+
+		workloadLabels := map[string]string{"foo": "workload-foo"}
+		namespaceLabels := map[string]string{
+			"foo":                    "namespace-foo",
+			"bar":                    "namespace-bar",
+			"team.example.com/owner": "alice",
+		}
+
+		assert.Equal(t, []string{"workload-foo", "namespace-bar", "alice"}, mappings.LabelValues(workloadLabels, namespaceLabels))
+		assert.Equal(t, []string{"namespace-foo", "namespace-bar", "alice"}, mappings.LabelValues(namespaceLabels, workloadLabels))
+		assert.Equal(t, []string{"workload-foo", "", ""}, mappings.LabelValues(workloadLabels, nil))
+		assert.Equal(t, []string{"", "", ""}, mappings.LabelValues())
+	})
+
+	t.Run("sanitize label names", func(t *testing.T) {
+		expectedMappings := Mappings{
+			{resourceLabelName: "app.kubernetes.io/name", prometheusLabelName: "app_kubernetes_io_name"},
+			{resourceLabelName: "app.kubernetes.io/part-of", prometheusLabelName: "part_of"},
+		}
+
+		mappings, err := ParseMappings("app.kubernetes.io/name,app.kubernetes.io/part-of=part_of")
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMappings, mappings)
+	})
+
+	t.Run("pattern mappings", func(t *testing.T) {
+		resourceLabels := map[string]string{
+			"app.kubernetes.io/name":         "foo",
+			"team.example.com/owner":         "alice",
+			"team.example.com/cost-center":   "1234",
+			"team.example.com/unknown":       "ignored",
+			"billing.example.com/cost-level": "high",
+			"billing.example.io/cost-level":  "low",
+		}
+
+		mappings, err := ParseMappings("app.kubernetes.io/name=app,team.example.com/*[owner,cost_center],~^billing\\.example\\.(com|io)/cost-(.*)$=cost_$2[cost_level]")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app", "owner", "cost_center", "cost_level"}, mappings.LabelNames())
+		assert.Equal(t, []string{"foo", "alice", "1234", "high"}, mappings.LabelValues(resourceLabels))
+		assert.Equal(t, []string{"", "", "", ""}, mappings.LabelValues(nil))
+		assert.Equal(t,
+			"app.kubernetes.io/name=app,team.example.com/*=$1[owner,cost_center],~^billing\\.example\\.(com|io)/cost-(.*)$=cost_$2[cost_level]",
+			mappings.String(),
+		)
+
+		var reparsed Mappings
+		assert.NoError(t, reparsed.Set(mappings.String()))
+		assert.Equal(t, mappings.LabelValues(resourceLabels), reparsed.LabelValues(resourceLabels))
+	})
+
+	t.Run("template references", func(t *testing.T) {
+		resourceLabels := map[string]string{
+			"team.example.com/owner": "alice",
+			"billing.example.com/id": "1234",
+		}
+
+		mappings, err := ParseMappings("team.example.com/*=${1}_name[owner_name],~^billing\\.example\\.com/(?P<key>.*)$=billing_$key[billing_id]")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice", "1234"}, mappings.LabelValues(resourceLabels))
+	})
+
+	t.Run("transforms", func(t *testing.T) {
+		resourceLabels := map[string]string{
+			"team":                   " Team-A ",
+			"version":                "0123456789abcdef0123456789abcdef01234567",
+			"team.example.com/owner": "Alice",
+		}
+
+		mappings, err := ParseMappings("team;trim;lower,env;default=unknown,version;maxlen=7,team.example.com/*[owner,cost_center];lower;default=none")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"team", "env", "version", "owner", "cost_center"}, mappings.LabelNames())
+		assert.Equal(t, []string{"team-a", "unknown", "0123456", "alice", "none"}, mappings.LabelValues(resourceLabels))
+		assert.Equal(t, []string{"", "unknown", "", "none", "none"}, mappings.LabelValues())
+		assert.Equal(t,
+			"team=team;trim;lower,env=env;default=unknown,version=version;maxlen=7,team.example.com/*=$1[owner,cost_center];lower;default=none",
+			mappings.String(),
+		)
+	})
+
+	t.Run("escaped transform arguments", func(t *testing.T) {
+		mappings, err := ParseMappings(`team;default=a\,b,env;replace=[\;\,]=>-`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"team", "env"}, mappings.LabelNames())
+		assert.Equal(t, []string{"a,b", "prod-eu-1"}, mappings.LabelValues(map[string]string{"env": "prod;eu,1"}))
+		assert.Equal(t, `team=team;default=a\,b,env=env;replace=[\;\,]=>-`, mappings.String())
+
+		var reparsed Mappings
+		assert.NoError(t, reparsed.Set(mappings.String()))
+		assert.Equal(t, mappings.LabelNames(), reparsed.LabelNames())
+	})
+
+	t.Run("transforms after fallback", func(t *testing.T) {
+		mappings, err := ParseMappings("team;lower;default=none")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"foo"}, mappings.LabelValues(map[string]string{}, map[string]string{"team": "FOO"}))
+	})
+
 	t.Run("invalid input", func(t *testing.T) {
-		for _, input := range []string{"", "foo=,bar=baz", "=foo"} {
+		for _, input := range []string{
+			"",
+			"foo=,bar=baz",
+			"=foo",
+			"foo=app.kubernetes.io/name",
+			"foo=__name__",
+			"namespace",
+			"team=ocean_id",
+			"foo,bar=foo",
+			"team,owner=team",
+			"team.example.com/*",
+			"team.example.com/*[]",
+			"team.example.com/*=[owner]",
+			"team.*.com/*[owner]",
+			"team.example.com/*[owner,namespace]",
+			"owner,team.example.com/*[owner]",
+			"~team.example.com/([a-[owner]",
+			"team;upper",
+			"team;maxlen=-1",
+			"team.example.com/*[owner];replace=[a-",
+			"~^team\\.example\\.com/.*$[owner]",
+			"~^team\\.example\\.com/(.*)$=$2[owner]",
+			"team.example.com/*=$1_id[owner_id]",
+			"~^team/(?P<role>.*)$=${owner}[owner]",
+		} {
 			_, err := ParseMappings(input)
 			assert.Error(t, err)
 		}
@@ -51,5 +175,8 @@ func TestMappings(t *testing.T) {
 
 		assert.NoError(t, mappings.Set("bar=baz,baz=qux"))
 		assert.Equal(t, expectedMappings, mappings)
+
+		assert.Error(t, mappings.Set("qux=foo"))
+		assert.Equal(t, expectedMappings, mappings)
 	})
 }
@@ -0,0 +1,76 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	errInvalidLabelName  = errors.New("invalid Prometheus label name")
+	errReservedLabelName = errors.New("label name is reserved")
+	errDuplicateLabel    = errors.New("duplicate label name")
+
+	labelNameRegex   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	invalidCharRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// reservedNames are the names of the labels set by the exporter itself.
+var reservedNames = map[string]bool{
+	"ocean_id":   true,
+	"ocean_name": true,
+	"namespace":  true,
+	"name":       true,
+	"workload":   true,
+	"container":  true,
+	"days_ago":   true,
+}
+
+// ValidateName returns an error if name is not a valid Prometheus label name,
+// is reserved for internal use by Prometheus (prefixed with `__`) or
+// collides with a label set by the exporter itself.
+func ValidateName(name string) error {
+	if !labelNameRegex.MatchString(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("%w: %q", errInvalidLabelName, name)
+	}
+
+	if reservedNames[name] {
+		return fmt.Errorf("%w: %q", errReservedLabelName, name)
+	}
+
+	return nil
+}
+
+// SanitizeName converts name into a valid Prometheus label name by replacing
+// all invalid characters with underscores, e.g. `app.kubernetes.io/name`
+// becomes `app_kubernetes_io_name`. Names starting with a digit are prefixed
+// with an underscore.
+func SanitizeName(name string) string {
+	sanitized := invalidCharRegex.ReplaceAllString(name, "_")
+
+	if sanitized != "" && sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "_" + sanitized
+	}
+
+	return sanitized
+}
+
+// ValidateUnique returns an error if any label name appears more than once in
+// the given lists of label names, e.g. because the same name is configured
+// via different flags.
+func ValidateUnique(names ...[]string) error {
+	seen := make(map[string]bool)
+
+	for _, list := range names {
+		for _, name := range list {
+			if seen[name] {
+				return fmt.Errorf("%w: %q", errDuplicateLabel, name)
+			}
+
+			seen[name] = true
+		}
+	}
+
+	return nil
+}
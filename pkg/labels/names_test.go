@@ -0,0 +1,48 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	testCases := []struct {
+		name        string
+		expectedErr string
+	}{
+		{name: "team"},
+		{name: "_team"},
+		{name: "app_kubernetes_io_name"},
+		{name: "", expectedErr: `invalid Prometheus label name: ""`},
+		{name: "app.kubernetes.io/name", expectedErr: `invalid Prometheus label name: "app.kubernetes.io/name"`},
+		{name: "1team", expectedErr: `invalid Prometheus label name: "1team"`},
+		{name: "__name__", expectedErr: `invalid Prometheus label name: "__name__"`},
+		{name: "namespace", expectedErr: `label name is reserved: "namespace"`},
+		{name: "ocean_id", expectedErr: `label name is reserved: "ocean_id"`},
+		{name: "days_ago", expectedErr: `label name is reserved: "days_ago"`},
+		{name: "date"},
+	}
+
+	for _, testCase := range testCases {
+		err := ValidateName(testCase.name)
+		if testCase.expectedErr == "" {
+			assert.NoError(t, err, testCase.name)
+		} else {
+			assert.EqualError(t, err, testCase.expectedErr, testCase.name)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "team", SanitizeName("team"))
+	assert.Equal(t, "app_kubernetes_io_name", SanitizeName("app.kubernetes.io/name"))
+	assert.Equal(t, "_1password_com_item", SanitizeName("1password.com/item"))
+	assert.Equal(t, "", SanitizeName(""))
+}
+
+func TestValidateUnique(t *testing.T) {
+	assert.NoError(t, ValidateUnique([]string{"foo", "bar"}, nil, []string{"baz"}))
+	assert.EqualError(t, ValidateUnique([]string{"foo", "foo"}), `duplicate label name: "foo"`)
+	assert.EqualError(t, ValidateUnique([]string{"foo"}, []string{"bar", "foo"}), `duplicate label name: "foo"`)
+}
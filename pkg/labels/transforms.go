@@ -0,0 +1,109 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var errUnknownTransform = errors.New("unknown label value transform, must be one of default=<value>, lower, trim, replace=<regex>[=><replacement>], maxlen=<length>")
+
+// transform modifies the value of a mapped label.
+type transform struct {
+	// spec is the textual representation of the transform.
+	spec  string
+	apply func(value string) string
+}
+
+// parseTransform parses a label value transform. Supported are:
+//
+//   - `default=value`: replaces empty values with value.
+//   - `lower`: converts the value to lower case.
+//   - `trim`: removes leading and trailing whitespace.
+//   - `replace=regex[=>replacement]`: replaces all matches of regex with
+//     replacement, which may reference capture groups. Matches are removed if
+//     no replacement is given.
+//   - `maxlen=length`: truncates the value to at most length characters.
+//
+// Commas and `;` in arguments, including the value of `default=`, must be
+// escaped in the input of ParseMappings and are unescaped before spec is
+// passed to parseTransform.
+//
+// Returns an error if the transform is unknown or its argument is invalid.
+func parseTransform(spec string) (transform, error) {
+	name, arg, _ := strings.Cut(spec, "=")
+
+	switch name {
+	case "default":
+		return transform{spec: spec, apply: func(value string) string {
+			if value == "" {
+				return arg
+			}
+
+			return value
+		}}, nil
+	case "lower":
+		return transform{spec: spec, apply: strings.ToLower}, nil
+	case "trim":
+		return transform{spec: spec, apply: strings.TrimSpace}, nil
+	case "replace":
+		expr, replacement, _ := strings.Cut(arg, "=>")
+		if expr == "" {
+			return transform{}, fmt.Errorf("invalid label value transform %q: regex must not be empty", spec)
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return transform{}, fmt.Errorf("invalid label value transform %q: %w", spec, err)
+		}
+
+		return transform{spec: spec, apply: func(value string) string {
+			return regex.ReplaceAllString(value, replacement)
+		}}, nil
+	case "maxlen":
+		length, err := strconv.Atoi(arg)
+		if err != nil || length <= 0 {
+			return transform{}, fmt.Errorf("invalid label value transform %q: length must be a positive integer", spec)
+		}
+
+		return transform{spec: spec, apply: func(value string) string {
+			return truncate(value, length)
+		}}, nil
+	default:
+		return transform{}, fmt.Errorf("%w: %q", errUnknownTransform, spec)
+	}
+}
+
+// parseTransforms parses a `;`-separated list of label value transforms.
+// Commas and `;` escaped with a backslash are part of the transforms.
+func parseTransforms(input string) ([]transform, error) {
+	specs := splitUnescaped(input, ';')
+	transforms := make([]transform, 0, len(specs))
+
+	for _, spec := range specs {
+		t, err := parseTransform(entryUnescaper.Replace(spec))
+		if err != nil {
+			return nil, err
+		}
+
+		transforms = append(transforms, t)
+	}
+
+	return transforms, nil
+}
+
+// truncate shortens value to at most length runes.
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+
+	return string(runes[:length])
+}
@@ -0,0 +1,41 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransform(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		value    string
+		expected string
+	}{
+		{spec: "default=unknown", value: "", expected: "unknown"},
+		{spec: "default=unknown", value: "foo", expected: "foo"},
+		{spec: "default=", value: "", expected: ""},
+		{spec: "lower", value: "Team-A", expected: "team-a"},
+		{spec: "trim", value: "  foo\t", expected: "foo"},
+		{spec: "replace=[0-9a-f]{40}=>sha", value: "build-0123456789abcdef0123456789abcdef01234567", expected: "build-sha"},
+		{spec: "replace=^v(\\d+)\\..*$=>v$1", value: "v1.2.3", expected: "v1"},
+		{spec: "replace=-canary", value: "foo-canary", expected: "foo"},
+		{spec: "maxlen=3", value: "foobar", expected: "foo"},
+		{spec: "maxlen=3", value: "fo", expected: "fo"},
+		{spec: "maxlen=2", value: "äöü", expected: "äö"},
+	}
+
+	for _, testCase := range testCases {
+		transform, err := parseTransform(testCase.spec)
+		require.NoError(t, err, testCase.spec)
+		assert.Equal(t, testCase.expected, transform.apply(testCase.value), testCase.spec)
+	}
+}
+
+func TestParseTransformErrors(t *testing.T) {
+	for _, spec := range []string{"", "upper", "replace=", "replace=[a-=>b", "maxlen=", "maxlen=0", "maxlen=foo"} {
+		_, err := parseTransform(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,178 @@
+// Package selfmetrics contains metrics about the exporter itself.
+package selfmetrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/pkg/apierrors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+)
+
+type clusterState struct {
+	name        string
+	up          bool
+	lastSuccess time.Time
+}
+
+// Metrics is a prometheus collector for metrics about the exporter itself. It
+// is safe for concurrent use. All methods are no-ops on a nil *Metrics.
+type Metrics struct {
+	scrapeDuration *prometheus.GaugeVec
+	apiErrors      *prometheus.CounterVec
+	clusterUp      *prometheus.Desc
+	lastSuccess    *prometheus.Desc
+	mu             sync.Mutex
+	clusters       map[string]map[string]*clusterState
+}
+
+// New creates a new *Metrics.
+func New() *Metrics {
+	return &Metrics{
+		scrapeDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "spotinst",
+				Subsystem: "exporter",
+				Name:      "scrape_duration_seconds",
+				Help:      "Duration of the last scrape of a collector",
+			},
+			[]string{"collector"},
+		),
+		apiErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "spotinst",
+				Subsystem: "exporter",
+				Name:      "api_errors_total",
+				Help:      "Total number of failed Spotinst API calls",
+			},
+			[]string{"endpoint", "class"},
+		),
+		clusterUp: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "exporter", "cluster_up"),
+			"Whether the last attempt to fetch the data of an ocean cluster was successful",
+			[]string{"collector", "ocean_id", "ocean_name"},
+			nil,
+		),
+		lastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName("spotinst", "exporter", "cluster_last_success_timestamp_seconds"),
+			"Unix timestamp of the last successful fetch of the data of an ocean cluster",
+			[]string{"collector", "ocean_id", "ocean_name"},
+			nil,
+		),
+		clusters: make(map[string]map[string]*clusterState),
+	}
+}
+
+// ObserveScrapeDuration records the duration of a collector's scrape.
+func (m *Metrics) ObserveScrapeDuration(collector string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.scrapeDuration.WithLabelValues(collector).Set(duration.Seconds())
+}
+
+// ObserveAPIError counts a failed call of a Spotinst API endpoint.
+func (m *Metrics) ObserveAPIError(endpoint string, err error) {
+	if m == nil {
+		return
+	}
+
+	m.apiErrors.WithLabelValues(endpoint, apierrors.Class(err)).Inc()
+}
+
+// ObserveClusterFetch records the outcome of fetching the data of an ocean
+// cluster for a collector.
+func (m *Metrics) ObserveClusterFetch(collector string, cluster *aws.Cluster, err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	states, ok := m.clusters[collector]
+	if !ok {
+		states = make(map[string]*clusterState)
+		m.clusters[collector] = states
+	}
+
+	clusterID := spotinst.StringValue(cluster.ID)
+
+	state, ok := states[clusterID]
+	if !ok {
+		state = &clusterState{}
+		states[clusterID] = state
+	}
+
+	state.name = spotinst.StringValue(cluster.Name)
+	state.up = err == nil
+
+	if err == nil {
+		state.lastSuccess = time.Now()
+	}
+}
+
+// RetainClusters forgets the state of all clusters of a collector that are
+// not in clusters.
+func (m *Metrics) RetainClusters(collector string, clusters []*aws.Cluster) {
+	if m == nil {
+		return
+	}
+
+	retain := make(map[string]struct{}, len(clusters))
+
+	for _, cluster := range clusters {
+		retain[spotinst.StringValue(cluster.ID)] = struct{}{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for clusterID := range m.clusters[collector] {
+		if _, ok := retain[clusterID]; !ok {
+			delete(m.clusters[collector], clusterID)
+		}
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.scrapeDuration.Describe(ch)
+	m.apiErrors.Describe(ch)
+	ch <- m.clusterUp
+	ch <- m.lastSuccess
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.scrapeDuration.Collect(ch)
+	m.apiErrors.Collect(ch)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for collector, states := range m.clusters {
+		for clusterID, state := range states {
+			labelValues := []string{collector, clusterID, state.name}
+
+			up := 0.0
+			if state.up {
+				up = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(m.clusterUp, prometheus.GaugeValue, up, labelValues...)
+
+			if !state.lastSuccess.IsZero() {
+				ch <- prometheus.MustNewConstMetric(
+					m.lastSuccess,
+					prometheus.GaugeValue,
+					float64(state.lastSuccess.UnixNano())/1e9,
+					labelValues...,
+				)
+			}
+		}
+	}
+}
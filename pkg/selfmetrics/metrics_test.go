@@ -0,0 +1,66 @@
+package selfmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bonial-International-GmbH/spotinst-metrics-exporter/internal/testhelpers"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spotinst/spotinst-sdk-go/service/ocean/providers/aws"
+	"github.com/spotinst/spotinst-sdk-go/spotinst"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	var nilMetrics *Metrics
+	nilMetrics.ObserveAPIError("GetClusterCosts", errors.New("boom"))
+	nilMetrics.ObserveClusterFetch("costs", oceanCluster("foo"), nil)
+
+	m := New()
+	m.ObserveAPIError("GetClusterCosts", testhelpers.APIError(http.StatusServiceUnavailable))
+	m.ObserveAPIError("GetClusterCosts", testhelpers.APIError(http.StatusServiceUnavailable))
+	m.ObserveAPIError("ListClusters", context.DeadlineExceeded)
+	m.ObserveClusterFetch("costs", oceanCluster("foo"), nil)
+	m.ObserveClusterFetch("costs", oceanCluster("bar"), errors.New("boom"))
+	m.ObserveClusterFetch("costs", oceanCluster("baz"), nil)
+	m.ObserveClusterFetch("suggestions", oceanCluster("foo"), errors.New("boom"))
+	m.RetainClusters("costs", []*aws.Cluster{oceanCluster("foo"), oceanCluster("bar")})
+	m.ObserveScrapeDuration("costs", 1500*time.Millisecond)
+
+	expected := `
+        # HELP spotinst_exporter_api_errors_total Total number of failed Spotinst API calls
+        # TYPE spotinst_exporter_api_errors_total counter
+        spotinst_exporter_api_errors_total{class="server_error",endpoint="GetClusterCosts"} 2
+        spotinst_exporter_api_errors_total{class="timeout",endpoint="ListClusters"} 1
+        # HELP spotinst_exporter_cluster_up Whether the last attempt to fetch the data of an ocean cluster was successful
+        # TYPE spotinst_exporter_cluster_up gauge
+        spotinst_exporter_cluster_up{collector="costs",ocean_id="bar",ocean_name="ocean-bar"} 0
+        spotinst_exporter_cluster_up{collector="costs",ocean_id="foo",ocean_name="ocean-foo"} 1
+        spotinst_exporter_cluster_up{collector="suggestions",ocean_id="foo",ocean_name="ocean-foo"} 0
+        # HELP spotinst_exporter_scrape_duration_seconds Duration of the last scrape of a collector
+        # TYPE spotinst_exporter_scrape_duration_seconds gauge
+        spotinst_exporter_scrape_duration_seconds{collector="costs"} 1.5
+    `
+
+	assert.NoError(t, testutil.CollectAndCompare(
+		m,
+		strings.NewReader(expected),
+		"spotinst_exporter_api_errors_total",
+		"spotinst_exporter_cluster_up",
+		"spotinst_exporter_scrape_duration_seconds",
+	))
+
+	// Only successfully fetched clusters have a last success timestamp.
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "spotinst_exporter_cluster_last_success_timestamp_seconds"))
+}
+
+func oceanCluster(id string) *aws.Cluster {
+	return &aws.Cluster{
+		ID:   spotinst.String(id),
+		Name: spotinst.String("ocean-" + id),
+	}
+}